@@ -0,0 +1,292 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"verbio_speech_center/log"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatExtensible = 0xFFFE
+	pcmBitDepth         = 16
+	bytesPerSample      = pcmBitDepth / 8
+	// maxWavFmtSize bounds the fmt chunk, which is 40 bytes at most for the
+	// formats in use.
+	maxWavFmtSize = 1024
+)
+
+// wavSubFormatSuffix ends every SubFormat GUID of WAVE_FORMAT_EXTENSIBLE; its
+// first two bytes are the format tag the GUID stands for.
+var wavSubFormatSuffix = []byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+// audioData holds the LPCM samples of an audio file, without any container
+// header, together with the format needed to configure the recognition.
+type audioData struct {
+	samples    []byte
+	sampleRate uint32
+	channels   uint16
+}
+
+// wavContents is the undecoded data chunk of a WAV file and its fmt header.
+type wavContents struct {
+	data       []byte
+	formatTag  uint16
+	bitDepth   uint16
+	sampleRate uint32
+	channels   uint16
+}
+
+// loadAudio reads a WAV or FLAC file and returns its samples as 16-bit LPCM.
+// When targetRate is zero the file must be 16-bit LPCM, which is sent as is,
+// G.711 or FLAC, which are decoded at their own rate; otherwise any supported
+// format is converted to mono at targetRate.
+func loadAudio(file string, targetRate int) (*audioData, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Logger.Errorf("Error closing audio file: %+v", err)
+		}
+	}()
+
+	contents, err := readAudio(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var audio *audioData
+	if targetRate == 0 {
+		audio, err = contents.lpcm16()
+	} else {
+		audio, err = contents.convert(targetRate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return audio, nil
+}
+
+// loadChannels reads a multi-channel WAV or FLAC file and returns one mono LPCM
+// stream per channel, converted to targetRate when it is not zero.
+func loadChannels(file string, targetRate int) ([]*audioData, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Logger.Errorf("Error closing audio file: %+v", err)
+		}
+	}()
+
+	contents, err := readAudio(f)
+	if err != nil {
+		return nil, err
+	}
+	if contents.channels < 2 {
+		return nil, fmt.Errorf("per-channel recognition needs a multi-channel file, got %d channel", contents.channels)
+	}
+
+	channels := make([]*audioData, contents.channels)
+	for i := range channels {
+		mono := contents.channel(i)
+		if targetRate == 0 {
+			channels[i], err = mono.lpcm16()
+		} else {
+			channels[i], err = mono.convert(targetRate)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error loading channel %d: %w", i, err)
+		}
+	}
+	return channels, nil
+}
+
+func readWav(r io.ReadSeeker) (*wavContents, error) {
+	decoder := wav.NewDecoder(r)
+	if !decoder.IsValidFile() {
+		if err := decoder.Err(); err != nil {
+			return nil, fmt.Errorf("not a valid WAV file: %w", err)
+		}
+		return nil, errors.New("not a valid WAV file")
+	}
+	if decoder.SampleRate == 0 {
+		return nil, errors.New("invalid WAV sample rate 0")
+	}
+
+	if err := decoder.FwdToPCM(); err != nil {
+		return nil, fmt.Errorf("error locating WAV data chunk: %w", err)
+	}
+	if decoder.PCMChunk == nil {
+		return nil, errors.New("WAV file has no data chunk")
+	}
+
+	data, err := io.ReadAll(decoder.PCMChunk)
+	if err != nil {
+		return nil, fmt.Errorf("error reading WAV data chunk: %w", err)
+	}
+	frameSize := int(decoder.NumChans) * int(decoder.BitDepth) / 8
+	if frameSize > 0 && len(data)%frameSize != 0 {
+		log.Logger.Warnf("WAV data chunk has a truncated trailing frame, dropping %d bytes", len(data)%frameSize)
+		data = data[:len(data)-len(data)%frameSize]
+	}
+
+	// The decoder does not parse the fmt chunk extension, which holds the
+	// actual format of WAVE_FORMAT_EXTENSIBLE files.
+	formatTag := decoder.WavAudioFormat
+	if formatTag == wavFormatExtensible {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("error rewinding WAV file: %w", err)
+		}
+		chunk, err := readWavFmtChunk(r)
+		if err != nil {
+			return nil, err
+		}
+		if formatTag, err = wavFormatTag(chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Logger.Debugf("Loaded WAV audio [format=%d] [bitDepth=%d] [sampleRate=%d] [channels=%d] [bytes=%d]",
+		formatTag, decoder.BitDepth, decoder.SampleRate, decoder.NumChans, len(data))
+	return &wavContents{
+		data:       data,
+		formatTag:  formatTag,
+		bitDepth:   decoder.BitDepth,
+		sampleRate: decoder.SampleRate,
+		channels:   decoder.NumChans,
+	}, nil
+}
+
+// readWavFmtChunk reads the RIFF header and the chunks up to and including the
+// fmt chunk, whose contents it returns, without seeking.
+func readWavFmtChunk(reader io.Reader) ([]byte, error) {
+	if _, err := io.CopyN(io.Discard, reader, 12); err != nil {
+		return nil, fmt.Errorf("error reading RIFF header: %w", err)
+	}
+	for {
+		var header struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+			return nil, fmt.Errorf("error reading WAV chunk header: %w", err)
+		}
+
+		switch string(header.ID[:]) {
+		case "fmt ":
+			if header.Size < 16 || header.Size > maxWavFmtSize {
+				return nil, fmt.Errorf("invalid WAV fmt chunk size %d", header.Size)
+			}
+			chunk := make([]byte, header.Size+header.Size%2)
+			if _, err := io.ReadFull(reader, chunk); err != nil {
+				return nil, fmt.Errorf("error reading WAV fmt chunk: %w", err)
+			}
+			return chunk[:header.Size], nil
+		case "data":
+			return nil, errors.New("WAV data chunk found before the fmt chunk")
+		default:
+			if err := skipChunk(reader, header.Size); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// wavFormatTag returns the format tag of a fmt chunk, resolving
+// WAVE_FORMAT_EXTENSIBLE to the tag of its SubFormat GUID.
+func wavFormatTag(chunk []byte) (uint16, error) {
+	formatTag := binary.LittleEndian.Uint16(chunk)
+	if formatTag != wavFormatExtensible {
+		return formatTag, nil
+	}
+	// The extension holds cbSize, wValidBitsPerSample and dwChannelMask
+	// before the 16-byte SubFormat GUID.
+	if len(chunk) < 40 {
+		return 0, fmt.Errorf("WAV fmt chunk too short for WAVE_FORMAT_EXTENSIBLE (%d bytes)", len(chunk))
+	}
+	subFormat := chunk[24:40]
+	if !bytes.Equal(subFormat[2:], wavSubFormatSuffix) {
+		return 0, fmt.Errorf("unsupported WAVE_FORMAT_EXTENSIBLE sub-format %x", subFormat)
+	}
+	return binary.LittleEndian.Uint16(subFormat), nil
+}
+
+func (w *wavContents) lpcm16() (*audioData, error) {
+	if encoding, ok := wavEncoding(w.formatTag); ok && encoding != EncodingPCM {
+		if w.bitDepth != g711BitDepth {
+			return nil, fmt.Errorf("unsupported WAV bit depth %d (%s audio must be %d-bit)", w.bitDepth, encoding, g711BitDepth)
+		}
+		return &audioData{
+			samples:    encoding.table().decode(w.data),
+			sampleRate: w.sampleRate,
+			channels:   w.channels,
+		}, nil
+	}
+	if w.formatTag != wavFormatPCM {
+		return nil, fmt.Errorf("unsupported WAV audio format %d (only LPCM and G.711 are supported without a target sample rate)", w.formatTag)
+	}
+	if w.bitDepth != pcmBitDepth {
+		return nil, fmt.Errorf("unsupported WAV bit depth %d (only %d-bit LPCM is supported without a target sample rate)", w.bitDepth, pcmBitDepth)
+	}
+	return &audioData{
+		samples:    w.data,
+		sampleRate: w.sampleRate,
+		channels:   w.channels,
+	}, nil
+}
+
+func (w *wavContents) convert(targetRate int) (*audioData, error) {
+	if w.formatTag == wavFormatPCM && w.bitDepth == pcmBitDepth && w.channels == 1 && int(w.sampleRate) == targetRate {
+		return w.lpcm16()
+	}
+
+	samples, err := decodeSamples(w.data, w.formatTag, w.bitDepth)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := ConvertToLPCM16(&audio.Float32Buffer{
+		Data:           samples,
+		Format:         &audio.Format{NumChannels: int(w.channels), SampleRate: int(w.sampleRate)},
+		SourceBitDepth: int(w.bitDepth),
+	}, targetRate)
+	if err != nil {
+		return nil, fmt.Errorf("error converting audio: %w", err)
+	}
+
+	log.Logger.Debugf("Converted audio from %d Hz (%d channels, %d-bit) to %d Hz mono 16-bit", w.sampleRate, w.channels, w.bitDepth, targetRate)
+	return &audioData{
+		samples:    converted,
+		sampleRate: uint32(targetRate),
+		channels:   1,
+	}, nil
+}
+
+// channel extracts a single channel of interleaved sample data.
+func (w *wavContents) channel(index int) *wavContents {
+	sampleSize := int(w.bitDepth) / 8
+	frameSize := sampleSize * int(w.channels)
+	frames := len(w.data) / frameSize
+	data := make([]byte, 0, frames*sampleSize)
+	for frame := 0; frame < frames; frame++ {
+		offset := frame*frameSize + index*sampleSize
+		data = append(data, w.data[offset:offset+sampleSize]...)
+	}
+	return &wavContents{
+		data:       data,
+		formatTag:  w.formatTag,
+		bitDepth:   w.bitDepth,
+		sampleRate: w.sampleRate,
+		channels:   1,
+	}
+}
@@ -0,0 +1,217 @@
+package verbio_speech_center
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/go-audio/audio"
+)
+
+const (
+	wavFormatIEEEFloat = 3
+
+	// resamplerZeroCrossings is the number of sinc zero crossings kept on each
+	// side of the anti-aliasing filter. Higher values give a sharper cut-off.
+	resamplerZeroCrossings = 16
+	// resamplerCutoff places the filter cut-off slightly below the Nyquist
+	// frequency of the lower of the two rates, leaving room for the transition band.
+	resamplerCutoff = 0.9
+	// resamplerKaiserBeta gives roughly 90 dB of stop-band attenuation.
+	resamplerKaiserBeta = 8.6
+)
+
+// SupportedTargetRates lists the sample rates the audio conversion stage can produce.
+var SupportedTargetRates = []int{8000, 16000}
+
+func validateTargetRate(rate int) error {
+	for _, supported := range SupportedTargetRates {
+		if rate == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported target sample rate %d (must be one of %v)", rate, SupportedTargetRates)
+}
+
+// ConvertToLPCM16 converts interleaved samples normalised to [-1, 1] into
+// 16-bit little-endian mono LPCM at targetRate. Multi-channel audio is
+// downmixed by averaging the channels and the signal is resampled through a
+// windowed-sinc anti-aliasing filter whenever the rates differ.
+func ConvertToLPCM16(buf *audio.Float32Buffer, targetRate int) ([]byte, error) {
+	if err := validateTargetRate(targetRate); err != nil {
+		return nil, err
+	}
+	if buf == nil || buf.Format == nil {
+		return nil, errors.New("audio buffer has no format")
+	}
+	if buf.Format.NumChannels < 1 {
+		return nil, fmt.Errorf("invalid number of channels %d", buf.Format.NumChannels)
+	}
+	if buf.Format.SampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate %d", buf.Format.SampleRate)
+	}
+
+	mono := downmix(buf.Data, buf.Format.NumChannels)
+	resampled := resample(mono, buf.Format.SampleRate, targetRate)
+	return quantizeLPCM16(resampled), nil
+}
+
+func downmix(samples []float32, channels int) []float32 {
+	if channels == 1 {
+		return samples
+	}
+	mono := make([]float32, len(samples)/channels)
+	for i := range mono {
+		var sum float32
+		for c := 0; c < channels; c++ {
+			sum += samples[i*channels+c]
+		}
+		mono[i] = sum / float32(channels)
+	}
+	return mono
+}
+
+func quantizeLPCM16(samples []float32) []byte {
+	out := make([]byte, len(samples)*bytesPerSample)
+	for i, sample := range samples {
+		value := math.Round(float64(sample) * 32768)
+		value = math.Max(math.MinInt16, math.Min(math.MaxInt16, value))
+		binary.LittleEndian.PutUint16(out[i*bytesPerSample:], uint16(int16(value)))
+	}
+	return out
+}
+
+// resample converts the sampling rate of a mono signal by the rational
+// factor up/down using a polyphase windowed-sinc filter.
+func resample(samples []float32, inRate int, outRate int) []float32 {
+	if inRate == outRate || len(samples) == 0 {
+		return samples
+	}
+
+	divisor := gcd(inRate, outRate)
+	up := outRate / divisor
+	down := inRate / divisor
+	phases := designPolyphaseFilter(up, down)
+	delay := (len(phases[0])*up - 1) / 2
+
+	out := make([]float32, int(int64(len(samples))*int64(up)/int64(down)))
+	for m := range out {
+		s := int64(m)*int64(down) + int64(delay)
+		phase := phases[s%int64(up)]
+		base := int(s / int64(up))
+		var acc float64
+		for k, coefficient := range phase {
+			index := base - k
+			if index < 0 {
+				break
+			}
+			if index < len(samples) {
+				acc += coefficient * float64(samples[index])
+			}
+		}
+		out[m] = float32(acc)
+	}
+	return out
+}
+
+// designPolyphaseFilter builds a Kaiser-windowed low-pass filter at the
+// upsampled rate and splits it into up phases, scaled by the upsampling gain.
+func designPolyphaseFilter(up int, down int) [][]float64 {
+	factor := max(up, down)
+	cutoff := resamplerCutoff * 0.5 / float64(factor)
+	tapsPerPhase := 2 * resamplerZeroCrossings * factor / up
+	length := tapsPerPhase * up
+	center := float64(length-1) / 2
+
+	phases := make([][]float64, up)
+	for p := range phases {
+		phases[p] = make([]float64, tapsPerPhase)
+	}
+	for j := 0; j < length; j++ {
+		x := float64(j) - center
+		value := 2 * cutoff * sinc(2*cutoff*x) * kaiser(x/center, resamplerKaiserBeta)
+		phases[j%up][j/up] = value * float64(up)
+	}
+	return phases
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+func kaiser(x float64, beta float64) float64 {
+	if x < -1 || x > 1 {
+		return 0
+	}
+	return besselI0(beta*math.Sqrt(1-x*x)) / besselI0(beta)
+}
+
+// besselI0 evaluates the zeroth-order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		half := x / (2 * float64(k))
+		term *= half * half
+		sum += term
+	}
+	return sum
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// decodeSamples converts raw little-endian WAV sample data into samples
+// normalised to [-1, 1].
+func decodeSamples(data []byte, formatTag uint16, bitDepth uint16) ([]float32, error) {
+	sampleSize := int(bitDepth) / 8
+	if sampleSize == 0 || int(bitDepth)%8 != 0 {
+		return nil, fmt.Errorf("unsupported WAV bit depth %d", bitDepth)
+	}
+
+	samples := make([]float32, len(data)/sampleSize)
+	switch {
+	case formatTag == wavFormatPCM && bitDepth == 8:
+		for i := range samples {
+			samples[i] = (float32(data[i]) - 128) / 128
+		}
+	case formatTag == wavFormatPCM && bitDepth == 16:
+		for i := range samples {
+			samples[i] = float32(int16(binary.LittleEndian.Uint16(data[i*2:]))) / (1 << 15)
+		}
+	case formatTag == wavFormatPCM && bitDepth == 24:
+		for i := range samples {
+			samples[i] = float32(audio.Int24LETo32(data[i*3:i*3+3])) / (1 << 23)
+		}
+	case formatTag == wavFormatPCM && bitDepth == 32:
+		for i := range samples {
+			samples[i] = float32(float64(int32(binary.LittleEndian.Uint32(data[i*4:]))) / (1 << 31))
+		}
+	case formatTag == wavFormatIEEEFloat && bitDepth == 32:
+		for i := range samples {
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+	case formatTag == wavFormatIEEEFloat && bitDepth == 64:
+		for i := range samples {
+			samples[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(data[i*8:])))
+		}
+	case formatTag == wavFormatMuLaw && bitDepth == g711BitDepth:
+		for i := range samples {
+			samples[i] = float32(muLawTable[data[i]]) / (1 << 15)
+		}
+	case formatTag == wavFormatALaw && bitDepth == g711BitDepth:
+		for i := range samples {
+			samples[i] = float32(aLawTable[data[i]]) / (1 << 15)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported WAV sample format %d with bit depth %d", formatTag, bitDepth)
+	}
+	return samples, nil
+}
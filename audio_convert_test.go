@@ -0,0 +1,219 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-audio/audio"
+	"github.com/stretchr/testify/assert"
+)
+
+func sineWave(frequency float64, sampleRate int, seconds float64, amplitude float64) []float32 {
+	samples := make([]float32, int(float64(sampleRate)*seconds))
+	for i := range samples {
+		samples[i] = float32(amplitude * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+	}
+	return samples
+}
+
+// rms computes the root mean square of 16-bit LPCM, ignoring the filter
+// warm-up at both ends.
+func rms(lpcm []byte) float64 {
+	samples := len(lpcm) / 2
+	skip := samples / 10
+	sum := 0.0
+	for i := skip; i < samples-skip; i++ {
+		value := float64(int16(binary.LittleEndian.Uint16(lpcm[i*2:]))) / 32768
+		sum += value * value
+	}
+	return math.Sqrt(sum / float64(samples-2*skip))
+}
+
+func TestConvertToLPCM16Resampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputRate  int
+		targetRate int
+		frequency  float64
+		passBand   bool
+	}{
+		{"48kHz to 16kHz pass-band", 48000, 16000, 1000, true},
+		{"44.1kHz to 16kHz pass-band", 44100, 16000, 1000, true},
+		{"44.1kHz to 8kHz pass-band", 44100, 8000, 440, true},
+		{"8kHz to 16kHz pass-band", 8000, 16000, 1000, true},
+		{"48kHz to 8kHz stop-band", 48000, 8000, 6000, false},
+		{"44.1kHz to 16kHz stop-band", 44100, 16000, 11000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &audio.Float32Buffer{
+				Data:   sineWave(tt.frequency, tt.inputRate, 0.5, 0.5),
+				Format: &audio.Format{NumChannels: 1, SampleRate: tt.inputRate},
+			}
+
+			out, err := ConvertToLPCM16(buf, tt.targetRate)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.targetRate/2, len(out)/2, 1)
+
+			expected := 0.5 / math.Sqrt2
+			if tt.passBand {
+				assert.InDelta(t, expected, rms(out), expected*0.02)
+			} else {
+				assert.True(t, rms(out) < expected*0.01, "tone above the target Nyquist frequency must be filtered out")
+			}
+		})
+	}
+}
+
+func TestConvertToLPCM16Downmix(t *testing.T) {
+	buf := &audio.Float32Buffer{
+		Data:   []float32{0.5, -0.5, 0.25, 0.75, 1, 1},
+		Format: &audio.Format{NumChannels: 2, SampleRate: 16000},
+	}
+
+	out, err := ConvertToLPCM16(buf, 16000)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x40, 0xff, 0x7f}, out)
+}
+
+func TestConvertToLPCM16Errors(t *testing.T) {
+	_, err := ConvertToLPCM16(&audio.Float32Buffer{Format: &audio.Format{NumChannels: 1, SampleRate: 16000}}, 22050)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported target sample rate 22050")
+
+	_, err = ConvertToLPCM16(&audio.Float32Buffer{}, 16000)
+	assert.Error(t, err)
+
+	_, err = ConvertToLPCM16(&audio.Float32Buffer{Format: &audio.Format{NumChannels: 0, SampleRate: 16000}}, 16000)
+	assert.Error(t, err)
+}
+
+func TestDecodeSamples(t *testing.T) {
+	float32Bytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(float32Bytes, math.Float32bits(-0.25))
+	float64Bytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(float64Bytes, math.Float64bits(0.125))
+
+	tests := []struct {
+		name      string
+		data      []byte
+		formatTag uint16
+		bitDepth  uint16
+		expected  []float32
+	}{
+		{"8-bit unsigned", []byte{0x00, 0x80, 0xc0}, wavFormatPCM, 8, []float32{-1, 0, 0.5}},
+		{"16-bit", []byte{0x00, 0x80, 0x00, 0x40}, wavFormatPCM, 16, []float32{-1, 0.5}},
+		{"24-bit", []byte{0x00, 0x00, 0x80, 0x00, 0x00, 0x40}, wavFormatPCM, 24, []float32{-1, 0.5}},
+		{"32-bit", []byte{0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, 0x40}, wavFormatPCM, 32, []float32{-1, 0.5}},
+		{"32-bit float", float32Bytes, wavFormatIEEEFloat, 32, []float32{-0.25}},
+		{"64-bit float", float64Bytes, wavFormatIEEEFloat, 64, []float32{0.125}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := decodeSamples(tt.data, tt.formatTag, tt.bitDepth)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, samples)
+		})
+	}
+
+	_, err := decodeSamples([]byte{0, 0}, wavFormatIEEEFloat, 16)
+	assert.Error(t, err)
+}
+
+// extensibleWav builds a WAVE_FORMAT_EXTENSIBLE file whose SubFormat GUID
+// stands for formatTag.
+func extensibleWav(formatTag uint16, sampleRate uint32, bitDepth uint16, channels uint16, data []byte) []byte {
+	blockAlign := channels * bitDepth / 8
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(4+8+40+8+len(data)))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []any{
+		uint32(40), uint16(wavFormatExtensible), channels, sampleRate, sampleRate * uint32(blockAlign), blockAlign, bitDepth,
+		uint16(22), bitDepth, uint32(0), formatTag,
+	} {
+		_ = binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.Write(wavSubFormatSuffix)
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestLoadAudioExtensible(t *testing.T) {
+	float32Bytes := make([]byte, 8)
+	binary.LittleEndian.PutUint32(float32Bytes, math.Float32bits(0.5))
+	binary.LittleEndian.PutUint32(float32Bytes[4:], math.Float32bits(-0.5))
+
+	tests := []struct {
+		name      string
+		formatTag uint16
+		bitDepth  uint16
+		data      []byte
+	}{
+		{"24-bit PCM", wavFormatPCM, 24, []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0xc0}},
+		{"32-bit float", wavFormatIEEEFloat, 32, float32Bytes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "audio.wav")
+			assert.NoError(t, os.WriteFile(file, extensibleWav(tt.formatTag, 8000, tt.bitDepth, 1, tt.data), 0600))
+
+			f, err := os.Open(file)
+			assert.NoError(t, err)
+			defer f.Close()
+			contents, err := readWav(f)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.formatTag, contents.formatTag)
+			assert.Equal(t, tt.bitDepth, contents.bitDepth)
+
+			samples, err := decodeSamples(contents.data, contents.formatTag, contents.bitDepth)
+			assert.NoError(t, err)
+			assert.Equal(t, []float32{0.5, -0.5}, samples)
+
+			audio, err := loadAudio(file, 8000)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte{0x00, 0x40, 0x00, 0xc0}, audio.samples)
+		})
+	}
+
+	file := filepath.Join(t.TempDir(), "audio.wav")
+	data := extensibleWav(wavFormatPCM, 8000, 16, 1, []byte{0, 0})
+	data[50] = 0xff
+	assert.NoError(t, os.WriteFile(file, data, 0600))
+	_, err := loadAudio(file, 8000)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported WAVE_FORMAT_EXTENSIBLE sub-format")
+}
+
+func TestLoadAudioWithTargetRate(t *testing.T) {
+	file := createTemporaryWav(t, 8000, 8, 2, []int{128, 128, 192, 192, 64, 64, 128, 128})
+
+	_, err := loadAudio(file, 0)
+	assert.Error(t, err)
+
+	audio, err := loadAudio(file, 16000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(16000), audio.sampleRate)
+	assert.Equal(t, uint16(1), audio.channels)
+	assert.Len(t, audio.samples, 16)
+}
+
+func TestWithTargetSampleRate(t *testing.T) {
+	recognizer, err := NewRecogniser("localhost:50051", createTemporaryToken(t), WithTargetSampleRate(16000))
+	assert.NoError(t, err)
+	assert.Equal(t, 16000, recognizer.options.targetSampleRate)
+	assert.NoError(t, recognizer.Close())
+
+	recognizer, err = NewRecogniser("localhost:50051", createTemporaryToken(t), WithTargetSampleRate(44100))
+	assert.Error(t, err)
+	assert.Nil(t, recognizer)
+}
@@ -0,0 +1,97 @@
+package verbio_speech_center
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTemporaryWav(t *testing.T, sampleRate int, bitDepth int, channels int, samples []int) string {
+	return createTemporaryWavFormat(t, wavFormatPCM, sampleRate, bitDepth, channels, samples)
+}
+
+func createTemporaryWavFormat(t *testing.T, formatTag int, sampleRate int, bitDepth int, channels int, samples []int) string {
+	file := filepath.Join(t.TempDir(), "audio.wav")
+	out, err := os.Create(file)
+	assert.NoError(t, err)
+
+	enc := wav.NewEncoder(out, sampleRate, bitDepth, channels, formatTag)
+	err = enc.Write(&audio.IntBuffer{
+		Data:           samples,
+		Format:         &audio.Format{NumChannels: channels, SampleRate: sampleRate},
+		SourceBitDepth: bitDepth,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, enc.Close())
+	assert.NoError(t, out.Close())
+	return file
+}
+
+func TestLoadAudio(t *testing.T) {
+	file := createTemporaryWav(t, 16000, 16, 1, []int{0, 1, -1, 32767, -32768})
+
+	audio, err := loadAudio(file, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(16000), audio.sampleRate)
+	assert.Equal(t, uint16(1), audio.channels)
+	assert.Equal(t, []byte{0x00, 0x00, 0x01, 0x00, 0xff, 0xff, 0xff, 0x7f, 0x00, 0x80}, audio.samples)
+}
+
+func TestLoadAudioStereo(t *testing.T) {
+	file := createTemporaryWav(t, 8000, 16, 2, []int{1, 2, 3, 4})
+
+	audio, err := loadAudio(file, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8000), audio.sampleRate)
+	assert.Equal(t, uint16(2), audio.channels)
+	assert.Len(t, audio.samples, 8)
+}
+
+func TestLoadAudioErrors(t *testing.T) {
+	raw := filepath.Join(t.TempDir(), "audio.raw")
+	assert.NoError(t, os.WriteFile(raw, []byte("this is not a RIFF container"), 0600))
+
+	tests := []struct {
+		name   string
+		file   string
+		errMsg string
+	}{
+		{
+			name:   "Non-existent file",
+			file:   "non-existent-file",
+			errMsg: "error reading audio file",
+		},
+		{
+			name:   "Not a WAV file",
+			file:   raw,
+			errMsg: "not a valid WAV file",
+		},
+		{
+			name:   "Unsupported bit depth",
+			file:   createTemporaryWav(t, 8000, 8, 1, []int{128, 129, 127}),
+			errMsg: "unsupported WAV bit depth 8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audio, err := loadAudio(tt.file, 0)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Nil(t, audio)
+		})
+	}
+}
+
+func TestTopicRequestAudioFormat(t *testing.T) {
+	request, err := RecognitionOptions{Topic: "generic", Language: "en-US"}.request(GrammarAuto, 16000, 2)
+	assert.NoError(t, err)
+
+	parameters := request.GetConfig().GetParameters()
+	assert.Equal(t, uint32(16000), parameters.GetPcm().GetSampleRateHz())
+	assert.Equal(t, uint32(2), parameters.GetAudioChannelsNumber())
+}
@@ -0,0 +1,251 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"verbio_speech_center/log"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// DefaultTokenRefreshMargin is how long before it expires a token fetched
+	// with client credentials is replaced by a new one.
+	DefaultTokenRefreshMargin = time.Minute
+	// DefaultTokenTimeout bounds every request made to the token endpoint.
+	DefaultTokenTimeout = 30 * time.Second
+	// tokenExpiryWarning is how close to its expiry a static token is
+	// reported when the client is created.
+	tokenExpiryWarning = time.Hour
+)
+
+// ClientCredentials authenticate the client with the OAuth 2.0 client
+// credentials grant: tokens are fetched from TokenURL as they are needed and
+// replaced before they expire, so long-running processes never send an
+// expired one.
+type ClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+	Scopes   []string
+	// RefreshMargin is how long before it expires a token is replaced. It
+	// defaults to DefaultTokenRefreshMargin.
+	RefreshMargin time.Duration
+	// Timeout bounds every request to the token endpoint, so that one that
+	// hangs fails the RPC waiting for the token. It defaults to
+	// DefaultTokenTimeout.
+	Timeout time.Duration
+}
+
+func (c ClientCredentials) validate() error {
+	if c.ClientID == "" {
+		return errors.New("client ID cannot be empty")
+	}
+	if c.ClientSecret == "" {
+		return errors.New("client secret cannot be empty")
+	}
+	endpoint, err := url.Parse(c.TokenURL)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return fmt.Errorf("invalid token URL %q (must be an absolute URL)", c.TokenURL)
+	}
+	if c.RefreshMargin < 0 {
+		return errors.New("token refresh margin cannot be negative")
+	}
+	if c.Timeout < 0 {
+		return errors.New("token timeout cannot be negative")
+	}
+	return nil
+}
+
+// tokenSource returns a source of tokens fetched from the token endpoint,
+// each of them reused until RefreshMargin before it expires.
+func (c ClientCredentials) tokenSource() oauth2.TokenSource {
+	margin := c.RefreshMargin
+	if margin == 0 {
+		margin = DefaultTokenRefreshMargin
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTokenTimeout
+	}
+	config := &clientcredentials.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		TokenURL:     c.TokenURL,
+		Scopes:       c.Scopes,
+	}
+	source := clientCredentialsSource{config: config, client: &http.Client{Timeout: timeout}}
+	return oauth2.ReuseTokenSourceWithExpiry(nil, source, margin)
+}
+
+// clientCredentialsSource fetches a new token on every call, with client.
+type clientCredentialsSource struct {
+	config *clientcredentials.Config
+	client *http.Client
+}
+
+func (s clientCredentialsSource) Token() (*oauth2.Token, error) {
+	log.Logger.Debugf("Fetching a token from %s", s.config.TokenURL)
+	token, err := s.config.Token(context.WithValue(context.Background(), oauth2.HTTPClient, s.client))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching token: %w", err)
+	}
+	log.Logger.Debugf("Fetched a token [expiry=%s]", token.Expiry.Format(time.RFC3339))
+	return token, nil
+}
+
+// WithClientCredentials makes the client authenticate with credentials
+// instead of a token file, which may then be left empty.
+func WithClientCredentials(credentials ClientCredentials) Option {
+	return func(o *clientOptions) error {
+		if err := credentials.validate(); err != nil {
+			return err
+		}
+		o.tokenSource = credentials.tokenSource()
+		return nil
+	}
+}
+
+// TokenClaims are the registered claims of a JWT bearer token.
+type TokenClaims struct {
+	Subject  string
+	Issuer   string
+	Audience []string
+	// IssuedAt and ExpiresAt are zero when the token does not carry them.
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// Claims holds every claim of the token.
+	Claims map[string]any
+}
+
+// Expired tells whether the token has expired at now.
+func (c *TokenClaims) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// ParseTokenClaims decodes the claims of a JWT token. The signature is not
+// verified: the claims are only used to tell when the token expires.
+func ParseTokenClaims(token string) (*TokenClaims, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT (expected three dot-separated parts)")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding token payload: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	claims := &TokenClaims{}
+	if err := decoder.Decode(&claims.Claims); err != nil {
+		return nil, fmt.Errorf("error parsing token claims: %w", err)
+	}
+	claims.Subject, _ = claims.Claims["sub"].(string)
+	claims.Issuer, _ = claims.Claims["iss"].(string)
+	switch audience := claims.Claims["aud"].(type) {
+	case string:
+		claims.Audience = []string{audience}
+	case []any:
+		for _, a := range audience {
+			if s, ok := a.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
+	}
+	if claims.IssuedAt, err = numericDate(claims.Claims, "iat"); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt, err = numericDate(claims.Claims, "exp"); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// LoadTokenClaims decodes the claims of the JWT token in file.
+func LoadTokenClaims(file string) (*TokenClaims, error) {
+	token, err := loadToken(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTokenClaims(token)
+}
+
+// numericDate reads a claim holding seconds since the epoch.
+func numericDate(claims map[string]any, name string) (time.Time, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("token claim %s is not a number", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("token claim %s is not a number", name)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+// staticTokenSource serves a token read from a file. When it is a JWT, a
+// token that has already expired is rejected, one about to expire is
+// reported, and the source fails once it expires instead of sending it.
+func staticTokenSource(token string) (oauth2.TokenSource, error) {
+	source := expiringTokenSource{token: &oauth2.Token{AccessToken: token, TokenType: "Bearer"}}
+	claims, err := ParseTokenClaims(token)
+	if err != nil {
+		log.Logger.Debugf("Cannot tell when the token expires: %v", err)
+		return source, nil
+	}
+	if claims.ExpiresAt.IsZero() {
+		return source, nil
+	}
+
+	now := time.Now()
+	if claims.Expired(now) {
+		return nil, fmt.Errorf("%w at %s", ErrTokenExpired, claims.ExpiresAt.Format(time.RFC3339))
+	}
+	if remaining := claims.ExpiresAt.Sub(now); remaining < tokenExpiryWarning {
+		log.Logger.Warnf("Token expires at %s, in %s", claims.ExpiresAt.Format(time.RFC3339), remaining.Round(time.Second))
+	}
+	source.token.Expiry = claims.ExpiresAt
+	return source, nil
+}
+
+// expiringTokenSource serves a single token until its expiry, if it has one.
+type expiringTokenSource struct {
+	token *oauth2.Token
+}
+
+func (s expiringTokenSource) Token() (*oauth2.Token, error) {
+	if !s.token.Expiry.IsZero() && !time.Now().Before(s.token.Expiry) {
+		return nil, fmt.Errorf("%w at %s", ErrTokenExpired, s.token.Expiry.Format(time.RFC3339))
+	}
+	return s.token, nil
+}
+
+// credentials returns the source of the tokens the client authenticates
+// with: its client credentials when it has them, the token in tokenFile
+// otherwise.
+func (o clientOptions) credentials(tokenFile string) (oauth2.TokenSource, error) {
+	if o.tokenSource != nil {
+		return o.tokenSource, nil
+	}
+	token, err := loadToken(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Logger.Infof("Loaded token from file: [%s]", tokenFile)
+	return staticTokenSource(token)
+}
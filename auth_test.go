@@ -0,0 +1,190 @@
+package verbio_speech_center
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// jwt builds an unsigned JWT carrying claims.
+func jwt(t *testing.T, claims map[string]any) string {
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode(payload) + ".signature"
+}
+
+func TestParseTokenClaims(t *testing.T) {
+	claims, err := ParseTokenClaims(jwt(t, map[string]any{
+		"sub": "client-1",
+		"iss": "https://auth.example.com",
+		"aud": "speechcenter",
+		"iat": 1700000000,
+		"exp": 1700003600.5,
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "client-1", claims.Subject)
+	assert.Equal(t, "https://auth.example.com", claims.Issuer)
+	assert.Equal(t, []string{"speechcenter"}, claims.Audience)
+	assert.Equal(t, time.Unix(1700000000, 0), claims.IssuedAt)
+	assert.Equal(t, time.Unix(1700003600, 5e8), claims.ExpiresAt)
+	assert.False(t, claims.Expired(time.Unix(1700003600, 0)))
+	assert.True(t, claims.Expired(time.Unix(1700003601, 0)))
+
+	claims, err = ParseTokenClaims(jwt(t, map[string]any{"aud": []string{"a", "b"}}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, claims.Audience)
+	assert.True(t, claims.ExpiresAt.IsZero())
+	assert.False(t, claims.Expired(time.Now()))
+
+	tests := []struct {
+		token  string
+		errMsg string
+	}{
+		{"test-token", "not a JWT"},
+		{"a.!!!.c", "error decoding token payload"},
+		{"a." + base64.RawURLEncoding.EncodeToString([]byte("[1]")) + ".c", "error parsing token claims"},
+		{jwt(t, map[string]any{"exp": "tomorrow"}), "exp is not a number"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTokenClaims(tt.token)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), tt.errMsg)
+	}
+}
+
+func TestStaticTokenSource(t *testing.T) {
+	source, err := staticTokenSource("test-token")
+	assert.NoError(t, err)
+	token, err := source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-token", token.AccessToken)
+	assert.True(t, token.Expiry.IsZero())
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	source, err = staticTokenSource(jwt(t, map[string]any{"exp": expiry.Unix()}))
+	assert.NoError(t, err)
+	token, err = source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, expiry, token.Expiry)
+	assert.Equal(t, "Bearer", token.TokenType)
+
+	_, err = staticTokenSource(jwt(t, map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}))
+	assert.True(t, errors.Is(err, ErrTokenExpired), "unexpected error: %v", err)
+
+	expired := expiringTokenSource{token: token}
+	expired.token.Expiry = time.Now().Add(-time.Second)
+	_, err = expired.Token()
+	assert.True(t, errors.Is(err, ErrTokenExpired), "unexpected error: %v", err)
+}
+
+func TestNewRecogniserWithExpiredToken(t *testing.T) {
+	tokenFile := createTemporaryTokenWith(t, jwt(t, map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}))
+	_, err := NewRecogniser("localhost:1", tokenFile)
+	assert.True(t, errors.Is(err, ErrTokenExpired), "unexpected error: %v", err)
+
+	_, err = NewSynthesizer("localhost:1", tokenFile)
+	assert.True(t, errors.Is(err, ErrTokenExpired), "unexpected error: %v", err)
+}
+
+// tokenEndpoint issues a numbered token per request, lasting the next of
+// lifetimes, or the last one once they run out.
+type tokenEndpoint struct {
+	mu        sync.Mutex
+	requests  int
+	lifetimes []int
+}
+
+func (e *tokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" {
+		http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != "client-1" || secret != "s3cret" {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	e.mu.Lock()
+	e.requests++
+	lifetime := e.lifetimes[min(e.requests, len(e.lifetimes))-1]
+	token := fmt.Sprintf("token-%d", e.requests)
+	e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"access_token": token, "token_type": "Bearer", "expires_in": lifetime})
+}
+
+func TestClientCredentialsRefreshToken(t *testing.T) {
+	endpoint := &tokenEndpoint{lifetimes: []int{30, 3600}}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	credentials := ClientCredentials{ClientID: "client-1", ClientSecret: "s3cret", TokenURL: server.URL}
+	source := credentials.tokenSource()
+
+	// The first token expires within the refresh margin, so it is replaced
+	// right away; the second one is reused.
+	var tokens []string
+	for range 3 {
+		token, err := source.Token()
+		assert.NoError(t, err)
+		tokens = append(tokens, token.AccessToken)
+	}
+	assert.Equal(t, []string{"token-1", "token-2", "token-2"}, tokens)
+	assert.Equal(t, 2, endpoint.requests)
+
+	credentials.ClientSecret = "wrong"
+	_, err := credentials.tokenSource().Token()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error fetching token")
+}
+
+func TestClientCredentialsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	credentials := ClientCredentials{ClientID: "client-1", ClientSecret: "s3cret", TokenURL: server.URL, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err := credentials.tokenSource().Token()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error fetching token")
+	assert.True(t, time.Since(start) < 5*time.Second, "token request was not given up on")
+}
+
+func TestWithClientCredentials(t *testing.T) {
+	server := httptest.NewServer(&tokenEndpoint{lifetimes: []int{3600}})
+	defer server.Close()
+
+	credentials := ClientCredentials{ClientID: "client-1", ClientSecret: "s3cret", TokenURL: server.URL}
+	recogniser, err := NewRecogniser("localhost:1", "", WithClientCredentials(credentials))
+	assert.NoError(t, err)
+	assert.NoError(t, recogniser.Close())
+
+	tests := []struct {
+		credentials ClientCredentials
+		errMsg      string
+	}{
+		{ClientCredentials{ClientSecret: "s3cret", TokenURL: server.URL}, "client ID cannot be empty"},
+		{ClientCredentials{ClientID: "client-1", TokenURL: server.URL}, "client secret cannot be empty"},
+		{ClientCredentials{ClientID: "client-1", ClientSecret: "s3cret", TokenURL: "auth/token"}, "invalid token URL"},
+		{ClientCredentials{ClientID: "client-1", ClientSecret: "s3cret", TokenURL: server.URL, RefreshMargin: -time.Second}, "refresh margin cannot be negative"},
+		{ClientCredentials{ClientID: "client-1", ClientSecret: "s3cret", TokenURL: server.URL, Timeout: -time.Second}, "token timeout cannot be negative"},
+	}
+	for _, tt := range tests {
+		_, err := NewSynthesizer("localhost:1", "", WithClientCredentials(tt.credentials))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), tt.errMsg)
+	}
+}
@@ -0,0 +1,395 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"verbio_speech_center/log"
+)
+
+// batchAudioExtensions are the files picked up when a batch is read from a
+// directory.
+var batchAudioExtensions = []string{".wav", ".flac", ".oga"}
+
+// BatchItem is a single audio file of a batch together with the settings it
+// is recognised with.
+type BatchItem struct {
+	Audio    string `json:"audio"`
+	Language string `json:"language,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	Grammar  string `json:"grammar,omitempty"`
+	// Reference is the expected transcript, used when evaluating accuracy.
+	Reference string `json:"reference,omitempty"`
+}
+
+// BatchOptions configures RecogniseBatch.
+type BatchOptions struct {
+	// Workers is the number of recognitions run at the same time over the
+	// connection of the Recogniser. It defaults to 1.
+	Workers int
+	// Recognition is applied to every item, whose grammar, topic and
+	// language take its place.
+	Recognition RecognitionOptions
+	// StateFile, when set, records every completed item so that an
+	// interrupted batch can be resumed without sending its audio again.
+	StateFile string
+	// OnResult receives the transcript of every completed item. An error
+	// marks the item as failed.
+	OnResult func(item BatchItem, transcript *Transcript) error
+}
+
+// BatchSummary reports the outcome of a batch.
+type BatchSummary struct {
+	Total     int            `json:"total"`
+	Completed int            `json:"completed"`
+	Skipped   int            `json:"skipped"`
+	Failed    int            `json:"failed"`
+	Failures  []BatchFailure `json:"failures,omitempty"`
+	Elapsed   time.Duration  `json:"elapsed_ns"`
+}
+
+// BatchFailure is an item of a batch that could not be recognised.
+type BatchFailure struct {
+	Item  BatchItem `json:"item"`
+	Error string    `json:"error"`
+}
+
+// LoadBatch lists the items of a batch. source is either a directory, whose
+// audio files are taken in name order, a JSONL or CSV manifest, or a glob
+// pattern. Manifest entries may set language, topic, grammar and reference
+// per file; whatever they leave empty is taken from defaults. Relative paths
+// in a manifest are resolved against the directory of the manifest; grammar
+// URIs are left as they are.
+func LoadBatch(source string, defaults BatchItem) ([]BatchItem, error) {
+	var items []BatchItem
+	info, err := os.Stat(source)
+	switch {
+	case err == nil && info.IsDir():
+		items, err = listBatchDirectory(source)
+	case err == nil && strings.EqualFold(filepath.Ext(source), ".jsonl"):
+		items, err = readJSONLManifest(source)
+	case err == nil && strings.EqualFold(filepath.Ext(source), ".csv"):
+		items, err = readCSVManifest(source)
+	default:
+		items, err = globBatch(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no audio files found in %s", source)
+	}
+
+	for i := range items {
+		if items[i].Language == "" {
+			items[i].Language = defaults.Language
+		}
+		if items[i].Topic == "" && items[i].Grammar == "" {
+			items[i].Topic = defaults.Topic
+			items[i].Grammar = defaults.Grammar
+		}
+	}
+	return items, nil
+}
+
+func listBatchDirectory(dir string) ([]BatchItem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory: %w", err)
+	}
+
+	items := make([]BatchItem, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !isBatchAudio(entry.Name()) {
+			continue
+		}
+		items = append(items, BatchItem{Audio: filepath.Join(dir, entry.Name())})
+	}
+	return items, nil
+}
+
+func globBatch(pattern string) ([]BatchItem, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	sort.Strings(matches)
+
+	items := make([]BatchItem, 0, len(matches))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			items = append(items, BatchItem{Audio: match})
+		}
+	}
+	return items, nil
+}
+
+func isBatchAudio(name string) bool {
+	for _, extension := range batchAudioExtensions {
+		if strings.EqualFold(filepath.Ext(name), extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// readJSONLManifest reads one JSON object per line. Blank lines are skipped.
+func readJSONLManifest(file string) ([]BatchItem, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening manifest: %w", err)
+	}
+	defer f.Close()
+
+	items := make([]BatchItem, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var item BatchItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return nil, fmt.Errorf("error parsing manifest line %d: %w", line, err)
+		}
+		if item.Audio == "" {
+			return nil, fmt.Errorf("manifest line %d has no audio", line)
+		}
+		items = append(items, resolveManifestItem(file, item))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	return items, nil
+}
+
+// readCSVManifest reads a CSV file whose header names the columns. Only the
+// audio column is required.
+func readCSVManifest(file string) ([]BatchItem, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening manifest: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["audio"]; !ok {
+		return nil, errors.New("manifest header has no audio column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	items := make([]BatchItem, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading manifest: %w", err)
+		}
+		item := BatchItem{
+			Audio:     field(record, "audio"),
+			Language:  field(record, "language"),
+			Topic:     field(record, "topic"),
+			Grammar:   field(record, "grammar"),
+			Reference: field(record, "reference"),
+		}
+		if item.Audio == "" {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("manifest line %d has no audio", line)
+		}
+		items = append(items, resolveManifestItem(file, item))
+	}
+	return items, nil
+}
+
+func resolveManifestItem(manifest string, item BatchItem) BatchItem {
+	dir := filepath.Dir(manifest)
+	if !filepath.IsAbs(item.Audio) {
+		item.Audio = filepath.Join(dir, item.Audio)
+	}
+	if item.Grammar != "" && !filepath.IsAbs(item.Grammar) && !grammarURIPattern.MatchString(item.Grammar) {
+		item.Grammar = filepath.Join(dir, item.Grammar)
+	}
+	return item
+}
+
+// batchState is the set of items a batch has already completed, backed by
+// an append-only JSONL file.
+type batchState struct {
+	mu        sync.Mutex
+	file      *os.File
+	completed map[string]bool
+}
+
+func openBatchState(file string) (*batchState, error) {
+	state := &batchState{completed: make(map[string]bool)}
+	if file == "" {
+		return state, nil
+	}
+
+	contents, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		var item BatchItem
+		// A line cut short by an interrupted write is ignored; its item runs again.
+		if json.Unmarshal([]byte(line), &item) == nil && item.Audio != "" {
+			state.completed[item.Audio] = true
+		}
+	}
+
+	state.file, err = os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening state file: %w", err)
+	}
+	// Start on a fresh line in case the last write was interrupted.
+	if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+		if _, err := state.file.Write([]byte("\n")); err != nil {
+			state.file.Close()
+			return nil, fmt.Errorf("error writing state file: %w", err)
+		}
+	}
+	return state, nil
+}
+
+func (s *batchState) isCompleted(item BatchItem) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.completed[item.Audio]
+}
+
+func (s *batchState) complete(item BatchItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completed[item.Audio] = true
+	if s.file == nil {
+		return nil
+	}
+
+	// The reference is not needed to resume a batch and can be long.
+	item.Reference = ""
+	line, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	return s.file.Sync()
+}
+
+func (s *batchState) close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// RecogniseBatch recognises every item with up to opts.Workers recognitions
+// in flight. A failed item is reported in the summary and does not stop the
+// others. Items recorded as completed in opts.StateFile are skipped. If ctx is
+// cancelled, the items still pending or in flight are left out of the summary,
+// so that a resumed batch runs them again, and the context error is returned
+// along with it.
+func (r *Recogniser) RecogniseBatch(ctx context.Context, items []BatchItem, opts BatchOptions) (*BatchSummary, error) {
+	start := time.Now()
+	state, err := openBatchState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	defer state.close()
+
+	workers := max(opts.Workers, 1)
+	log.Logger.Infof("Performing batch recognition [items=%d] [workers=%d]", len(items), workers)
+
+	summary := &BatchSummary{Total: len(items)}
+	var mu sync.Mutex
+	pending := make(chan BatchItem)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range pending {
+				err := r.recogniseBatchItem(ctx, item, opts, state)
+				mu.Lock()
+				if err != nil && ctx.Err() != nil {
+					log.Logger.Warnf("Interrupted %s: %+v", item.Audio, err)
+				} else if err != nil {
+					log.Logger.Errorf("Error recognising %s: %+v", item.Audio, err)
+					summary.Failed++
+					summary.Failures = append(summary.Failures, BatchFailure{Item: item, Error: err.Error()})
+				} else {
+					summary.Completed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		if state.isCompleted(item) {
+			log.Logger.Infof("Skipping %s, already completed", item.Audio)
+			summary.Skipped++
+			continue
+		}
+		select {
+		case pending <- item:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	summary.Elapsed = time.Since(start)
+	return summary, ctx.Err()
+}
+
+func (r *Recogniser) recogniseBatchItem(ctx context.Context, item BatchItem, opts BatchOptions, state *batchState) error {
+	recognition := opts.Recognition
+	recognition.Grammar = item.Grammar
+	recognition.Topic = item.Topic
+	recognition.Language = item.Language
+	transcript, err := r.Recognise(ctx, item.Audio, recognition)
+	if err != nil {
+		return err
+	}
+
+	if opts.OnResult != nil {
+		if err := opts.OnResult(item, transcript); err != nil {
+			return err
+		}
+	}
+	return state.complete(item)
+}
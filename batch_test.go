@@ -0,0 +1,184 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBatchDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "b.wav", nil)
+	writeTestFile(t, dir, "a.WAV", nil)
+	writeTestFile(t, dir, "notes.txt", nil)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "nested.wav"), 0755))
+
+	items, err := LoadBatch(dir, BatchItem{Language: "en-US", Topic: "generic"})
+	assert.NoError(t, err)
+	assert.Equal(t, []BatchItem{
+		{Audio: filepath.Join(dir, "a.WAV"), Language: "en-US", Topic: "generic"},
+		{Audio: filepath.Join(dir, "b.wav"), Language: "en-US", Topic: "generic"},
+	}, items)
+}
+
+func TestLoadBatchGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "call-2.wav", nil)
+	writeTestFile(t, dir, "call-1.wav", nil)
+	writeTestFile(t, dir, "other.wav", nil)
+
+	items, err := LoadBatch(filepath.Join(dir, "call-*.wav"), BatchItem{Language: "es-ES", Topic: "generic"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, filepath.Join(dir, "call-1.wav"), items[0].Audio)
+	assert.Equal(t, filepath.Join(dir, "call-2.wav"), items[1].Audio)
+}
+
+func TestLoadBatchJSONL(t *testing.T) {
+	dir := t.TempDir()
+	manifest := writeTestFile(t, dir, "manifest.jsonl", []byte(`{"audio": "a.wav"}
+
+{"audio": "/abs/b.wav", "language": "es-ES", "grammar": "g.bnf"}
+`))
+
+	items, err := LoadBatch(manifest, BatchItem{Language: "en-US", Topic: "generic"})
+	assert.NoError(t, err)
+	assert.Equal(t, []BatchItem{
+		{Audio: filepath.Join(dir, "a.wav"), Language: "en-US", Topic: "generic"},
+		{Audio: "/abs/b.wav", Language: "es-ES", Grammar: filepath.Join(dir, "g.bnf")},
+	}, items)
+}
+
+func TestLoadBatchCSV(t *testing.T) {
+	dir := t.TempDir()
+	manifest := writeTestFile(t, dir, "manifest.csv", []byte("Audio, topic, language\na.wav, banking,\nb.wav,, ca-ES\n"))
+
+	items, err := LoadBatch(manifest, BatchItem{Language: "en-US", Topic: "generic"})
+	assert.NoError(t, err)
+	assert.Equal(t, []BatchItem{
+		{Audio: filepath.Join(dir, "a.wav"), Language: "en-US", Topic: "banking"},
+		{Audio: filepath.Join(dir, "b.wav"), Language: "ca-ES", Topic: "generic"},
+	}, items)
+}
+
+func TestLoadBatchErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := LoadBatch(filepath.Join(dir, "*.wav"), BatchItem{})
+	assert.Error(t, err)
+
+	_, err = LoadBatch(writeTestFile(t, dir, "bad.jsonl", []byte("{\"language\": \"en-US\"}\n")), BatchItem{})
+	assert.Error(t, err)
+
+	_, err = LoadBatch(writeTestFile(t, dir, "bad.csv", []byte("language\nen-US\n")), BatchItem{})
+	assert.Error(t, err)
+}
+
+func TestBatchStateResume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state.jsonl")
+
+	state, err := openBatchState(file)
+	assert.NoError(t, err)
+	assert.NoError(t, state.complete(BatchItem{Audio: "a.wav"}))
+	assert.NoError(t, state.close())
+
+	// Simulate a run interrupted in the middle of writing a line.
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"audio": "b.w`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	state, err = openBatchState(file)
+	assert.NoError(t, err)
+	assert.True(t, state.isCompleted(BatchItem{Audio: "a.wav"}))
+	assert.False(t, state.isCompleted(BatchItem{Audio: "b.wav"}))
+	assert.NoError(t, state.complete(BatchItem{Audio: "b.wav"}))
+	assert.NoError(t, state.close())
+
+	state, err = openBatchState(file)
+	assert.NoError(t, err)
+	assert.True(t, state.isCompleted(BatchItem{Audio: "b.wav"}))
+	assert.NoError(t, state.close())
+}
+
+func TestRecogniseBatch(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{})
+	defer recogniser.Close()
+
+	audio := createTemporaryWav(t, 8000, 16, 1, make([]int, 800))
+	items := []BatchItem{
+		{Audio: audio, Language: "en-US", Topic: "generic"},
+		{Audio: audio + ".copy", Language: "es-ES", Topic: "generic"},
+		{Audio: audio, Language: "ca-ES"},
+	}
+	assert.NoError(t, os.Link(audio, audio+".copy"))
+	stateFile := filepath.Join(t.TempDir(), "state.jsonl")
+
+	var mu sync.Mutex
+	results := make([]string, 0)
+	opts := BatchOptions{
+		Workers:   2,
+		StateFile: stateFile,
+		OnResult: func(item BatchItem, transcript *Transcript) error {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, transcript.Text())
+			return nil
+		},
+	}
+	summary, err := recogniser.RecogniseBatch(context.Background(), items, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Total)
+	assert.Equal(t, 2, summary.Completed)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, items[2], summary.Failures[0].Item)
+	sort.Strings(results)
+	assert.Equal(t, []string{"en-US", "es-ES"}, results)
+
+	summary, err = recogniser.RecogniseBatch(context.Background(), items[:2], opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Skipped)
+	assert.Equal(t, 0, summary.Completed)
+	assert.Len(t, results, 2)
+}
+
+func TestRecogniseBatchCancelled(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{})
+	defer recogniser.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary, err := recogniser.RecogniseBatch(ctx, []BatchItem{{Audio: "a.wav", Topic: "generic"}}, BatchOptions{})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, summary.Total)
+	assert.Equal(t, 0, summary.Completed+summary.Failed)
+}
+
+func TestRecogniseBatchCancelledInFlight(t *testing.T) {
+	server := &fakeRecognizerServer{started: make(chan struct{}, 1), hang: true}
+	recogniser := newFakeServerRecogniser(t, server)
+	defer recogniser.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-server.started
+		cancel()
+	}()
+	stateFile := filepath.Join(t.TempDir(), "state.jsonl")
+	summary, err := recogniser.RecogniseBatch(ctx, []BatchItem{{Audio: spokenWav(t, 1), Topic: "generic"}}, BatchOptions{StateFile: stateFile})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, summary.Completed)
+	assert.Equal(t, 0, summary.Failed)
+	assert.Empty(t, summary.Failures)
+
+	state, err := openBatchState(stateFile)
+	assert.NoError(t, err)
+	defer state.close()
+	assert.Empty(t, state.completed)
+}
@@ -0,0 +1,190 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"verbio_speech_center/log"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+)
+
+// ChannelRecognition is the outcome of recognising every channel of a
+// multi-channel recording as an independent stream.
+type ChannelRecognition struct {
+	// Channels holds the transcript text of each channel, in channel order.
+	Channels []string
+	// Transcripts holds the structured transcript of each channel, in channel order.
+	Transcripts []*Transcript
+	// Dialogue holds the turns of all channels ordered by start time.
+	Dialogue []DialogueTurn
+}
+
+// DialogueTurn is a run of consecutive words spoken on the same channel.
+type DialogueTurn struct {
+	Channel int
+	Start   time.Duration
+	End     time.Duration
+	Text    string
+}
+
+func (r *Recogniser) RecogniseChannelsWithGrammar(ctx context.Context, audioFile string, grammarFile string, language string, wordBoosting []string) (*ChannelRecognition, error) {
+	if grammarFile == "" {
+		return nil, recognitionError(ErrInvalidOptions, errors.New("received an empty grammarFile path"))
+	}
+	return r.RecogniseChannels(ctx, audioFile, RecognitionOptions{Grammar: grammarFile, Language: language, WordBoosting: wordBoosting})
+}
+
+func (r *Recogniser) RecogniseChannelsWithTopic(ctx context.Context, audioFile string, topic string, language string, wordBoosting []string) (*ChannelRecognition, error) {
+	return r.RecogniseChannels(ctx, audioFile, RecognitionOptions{Topic: topic, Language: language, WordBoosting: wordBoosting})
+}
+
+// RecogniseChannels recognises every channel of audioFile as a mono stream
+// configured by opts. opts.Channels, when set, refers to the whole file.
+func (r *Recogniser) RecogniseChannels(ctx context.Context, audioFile string, opts RecognitionOptions) (*ChannelRecognition, error) {
+	log.Logger.Infof("Performing per-channel recognition [audioFile=%s] [grammar=%s] [topic=%s] [language=%s] [wordBoosting=%v]", audioFile, opts.Grammar, opts.Topic, opts.Language, opts.WordBoosting)
+	if err := opts.Validate(); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, fmt.Errorf("invalid recognition options: %w", err))
+	}
+	if opts.EndOfUtteranceSilence > 0 {
+		return nil, recognitionError(ErrInvalidOptions, errors.New("end-of-utterance detection is not supported in per-channel recognition"))
+	}
+	return r.performChannelRecognition(ctx, audioFile, opts)
+}
+
+// performChannelRecognition runs one recognition stream per channel at the
+// same time, all of them sharing the connection of r. The first channel to
+// fail cancels the recognition of the others.
+func (r *Recogniser) performChannelRecognition(ctx context.Context, audioFile string, opts RecognitionOptions) (*ChannelRecognition, error) {
+	channels, err := loadChannels(audioFile, r.options.targetSampleRate)
+	if err != nil {
+		return nil, audioLoadError(err)
+	}
+	if err := opts.checkChannels(uint16(len(channels))); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, err)
+	}
+	opts.Channels = 0
+
+	configurations := make([]*sttv1.RecognitionStreamingRequest, len(channels))
+	for i, audio := range channels {
+		if configurations[i], err = opts.request(r.options.grammarType, audio.sampleRate, audio.channels); err != nil {
+			return nil, err
+		}
+	}
+
+	transcripts, err := recogniseAll(ctx, len(channels), len(channels), "channel", func(ctx context.Context, i int) (*Transcript, error) {
+		log.Logger.Infof("Starting recognition of channel %d", i)
+		return r.performRecognition(ctx, channels[i], configurations[i], 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, len(transcripts))
+	for i, transcript := range transcripts {
+		texts[i] = transcript.Text()
+	}
+	return &ChannelRecognition{
+		Channels:    texts,
+		Transcripts: transcripts,
+		Dialogue:    buildDialogue(transcripts),
+	}, nil
+}
+
+// recogniseAll recognises n parts of an audio, named part in errors, with up
+// to workers of them in flight. The first failure cancels the others, and
+// is the one reported rather than the cancellations it caused.
+func recogniseAll(ctx context.Context, n int, workers int, part string, recognise func(ctx context.Context, i int) (*Transcript, error)) ([]*Transcript, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	transcripts := make([]*Transcript, n)
+	errs := make([]error, n)
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				transcripts[i], errs[i] = recognise(ctx, i)
+				if errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := range n {
+		pending <- i
+	}
+	close(pending)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, fmt.Errorf("error recognising %s %d: %w", part, i, err)
+		}
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("error recognising %s %d: %w", part, i, err)
+		}
+	}
+	return transcripts, nil
+}
+
+type channelWord struct {
+	Word
+	channel int
+}
+
+// buildDialogue sorts the words of every channel by start time and groups
+// consecutive words of the same channel into turns. Segments without word
+// timings are kept as a single unit spanning the whole segment.
+func buildDialogue(transcripts []*Transcript) []DialogueTurn {
+	words := make([]channelWord, 0)
+	for channel, transcript := range transcripts {
+		for _, segment := range transcript.Segments {
+			if len(segment.Words) == 0 && segment.Text != "" {
+				words = append(words, channelWord{
+					Word:    Word{Text: segment.Text, Start: segment.Start, End: segment.End},
+					channel: channel,
+				})
+			}
+			for _, word := range segment.Words {
+				words = append(words, channelWord{Word: word, channel: channel})
+			}
+		}
+	}
+
+	sort.SliceStable(words, func(i, j int) bool {
+		if words[i].Start != words[j].Start {
+			return words[i].Start < words[j].Start
+		}
+		return words[i].channel < words[j].channel
+	})
+
+	dialogue := make([]DialogueTurn, 0)
+	var text []string
+	for i, word := range words {
+		if i == 0 || word.channel != dialogue[len(dialogue)-1].Channel {
+			if len(dialogue) > 0 {
+				dialogue[len(dialogue)-1].Text = strings.Join(text, " ")
+			}
+			dialogue = append(dialogue, DialogueTurn{Channel: word.channel, Start: word.Start})
+			text = text[:0]
+		}
+		turn := &dialogue[len(dialogue)-1]
+		if word.End > turn.End {
+			turn.End = word.End
+		}
+		text = append(text, word.Text)
+	}
+	if len(dialogue) > 0 {
+		dialogue[len(dialogue)-1].Text = strings.Join(text, " ")
+	}
+	return dialogue
+}
@@ -0,0 +1,74 @@
+package verbio_speech_center
+
+import (
+	"testing"
+	"time"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func finalResult(transcript string, duration float32, words ...*sttv1.WordInfo) *sttv1.RecognitionResult {
+	return &sttv1.RecognitionResult{
+		Alternatives: []*sttv1.RecognitionAlternative{{Transcript: transcript, Words: words}},
+		Duration:     duration,
+		IsFinal:      true,
+	}
+}
+
+func word(text string, start float32, end float32) *sttv1.WordInfo {
+	return &sttv1.WordInfo{Word: text, StartTime: start, EndTime: end}
+}
+
+func TestBuildDialogue(t *testing.T) {
+	agent := []*sttv1.RecognitionResult{
+		finalResult("hello how can I help", 3,
+			word("hello", 0.5, 0.9), word("how", 1.0, 1.2), word("can", 1.2, 1.4), word("I", 1.4, 1.5), word("help", 1.5, 1.9)),
+		finalResult("sure", 2, word("sure", 4.0, 4.3)),
+	}
+	customer := []*sttv1.RecognitionResult{
+		finalResult("I lost my card", 4,
+			word("I", 2.1, 2.2), word("lost", 2.2, 2.5), word("my", 2.5, 2.6), word("card", 2.6, 3.0)),
+		finalResult("thanks", 1),
+	}
+
+	dialogue := buildDialogue([]*Transcript{newTranscript(agent), newTranscript(customer)})
+	assert.Equal(t, []DialogueTurn{
+		{Channel: 0, Start: secondsToDuration(0.5), End: secondsToDuration(1.9), Text: "hello how can I help"},
+		{Channel: 1, Start: secondsToDuration(2.1), End: secondsToDuration(3.0), Text: "I lost my card"},
+		{Channel: 0, Start: secondsToDuration(4.0), End: secondsToDuration(4.3), Text: "sure"},
+		{Channel: 1, Start: 4 * time.Second, End: 5 * time.Second, Text: "thanks"},
+	}, dialogue)
+}
+
+func TestBuildDialogueEmpty(t *testing.T) {
+	dialogue := buildDialogue([]*Transcript{newTranscript(nil), newTranscript(nil)})
+	assert.Empty(t, dialogue)
+}
+
+func TestLoadChannels(t *testing.T) {
+	file := createTemporaryWav(t, 8000, 16, 2, []int{1, -1, 2, -2, 3, -3})
+
+	channels, err := loadChannels(file, 0)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	assert.Equal(t, []byte{0x01, 0x00, 0x02, 0x00, 0x03, 0x00}, channels[0].samples)
+	assert.Equal(t, []byte{0xff, 0xff, 0xfe, 0xff, 0xfd, 0xff}, channels[1].samples)
+	assert.Equal(t, uint16(1), channels[1].channels)
+	assert.Equal(t, uint32(8000), channels[1].sampleRate)
+
+	channels, err = loadChannels(file, 16000)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	assert.Equal(t, uint32(16000), channels[0].sampleRate)
+	assert.Len(t, channels[0].samples, 12)
+}
+
+func TestLoadChannelsMono(t *testing.T) {
+	file := createTemporaryWav(t, 8000, 16, 1, []int{1, 2, 3})
+
+	channels, err := loadChannels(file, 0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "multi-channel")
+	assert.Nil(t, channels)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"verbio_speech_center"
+	"verbio_speech_center/log"
+)
+
+const (
+	batchStateFileName   = ".batch-state.jsonl"
+	batchSummaryFileName = "summary.json"
+)
+
+type BatchRecognizeOpts struct {
+	Input          string        `short:"i" long:"input" description:"Directory, glob pattern, or JSONL/CSV manifest of the audio files to recognise" required:"true"`
+	OutputDir      string        `short:"o" long:"output-dir" description:"Directory the results and the summary are written to" required:"true"`
+	Workers        int           `short:"j" long:"workers" description:"Number of recognitions run at the same time" default:"4"`
+	StateFile      string        `long:"state-file" description:"File recording the completed items, used to resume an interrupted run (default: .batch-state.jsonl in the output directory)"`
+	Grammar        string        `short:"g" long:"grammar" description:"Path or URI of the grammar used for files that set neither grammar nor topic"`
+	GrammarType    string        `long:"grammar-type" description:"How the grammar is sent: auto picks a URI, inline ABNF/GRXML source or a compiled grammar from the value and the file contents" choice:"auto" choice:"compiled" choice:"inline" choice:"uri" default:"auto"`
+	Topic          string        `short:"T" long:"topic" description:"Topic used for files that set neither grammar nor topic"`
+	Language       string        `short:"L" long:"language" description:"Language used for files that do not set one" default:"en-US"`
+	WordBoosting   []string      `short:"w" long:"word-boosting" description:"Word to boost during recognition (can be specified multiple times)"`
+	TargetRate     int           `long:"target-rate" description:"Convert the audio to 16-bit mono LPCM at this sample rate (8000 or 16000) before recognition"`
+	OutputFormat   string        `long:"output-format" description:"Format of the result files" choice:"text" choice:"srt" choice:"vtt" default:"text"`
+	MaxLineLength  int           `long:"max-line-length" description:"Maximum number of characters of a subtitle line" default:"42"`
+	MaxCueDuration time.Duration `long:"max-cue-duration" description:"Maximum time a subtitle cue stays on screen" default:"6s"`
+	MinPause       time.Duration `long:"min-pause" description:"Pause between two words that starts a new subtitle cue" default:"700ms"`
+	Speed          float64       `long:"speed" description:"Streaming speed as a multiple of real time (0 sends the audio unthrottled)" default:"1"`
+	ChunkMs        int           `long:"chunk-ms" description:"Milliseconds of audio sent in each request" default:"50"`
+	Reconnects     int           `long:"reconnects" description:"Times a failed stream is reopened, resuming after the last final result"`
+	ReconnectDelay time.Duration `long:"reconnect-backoff" description:"Wait before the first reconnection, doubled for every further one" default:"1s"`
+	RecognitionFlags
+}
+
+type BatchRecognizeCommand struct {
+	url       string
+	tokenFile string
+	cmd       *BatchRecognizeOpts
+}
+
+func NewBatchRecognizeCommand(url, tokenFile string, cmd *BatchRecognizeOpts) Command {
+	return &BatchRecognizeCommand{
+		url:       url,
+		tokenFile: tokenFile,
+		cmd:       cmd,
+	}
+}
+
+func (b *BatchRecognizeCommand) Execute(ctx context.Context) error {
+	items, err := verbio_speech_center.LoadBatch(b.cmd.Input, verbio_speech_center.BatchItem{
+		Language: b.cmd.Language,
+		Topic:    b.cmd.Topic,
+		Grammar:  b.cmd.Grammar,
+	})
+	if err != nil {
+		log.Logger.Fatalf("Error loading batch: %+v", err)
+	}
+	recognition, err := b.cmd.options("", "", "", b.cmd.WordBoosting)
+	if err != nil {
+		log.Logger.Fatalf("Error loading word boosting list: %+v", err)
+	}
+	if err := validateBatch(items, recognition); err != nil {
+		log.Logger.Fatalf("%v", err)
+	}
+	outputs, err := b.outputFiles(items)
+	if err != nil {
+		log.Logger.Fatalf("%v", err)
+	}
+	if err := os.MkdirAll(b.cmd.OutputDir, 0755); err != nil {
+		log.Logger.Fatalf("Error creating output directory: %+v", err)
+	}
+
+	opts := []verbio_speech_center.Option{
+		verbio_speech_center.WithPacing(verbio_speech_center.Pacing{
+			Speed:         b.cmd.Speed,
+			ChunkDuration: time.Duration(b.cmd.ChunkMs) * time.Millisecond,
+		}),
+	}
+	if b.cmd.TargetRate != 0 {
+		opts = append(opts, verbio_speech_center.WithTargetSampleRate(b.cmd.TargetRate))
+	}
+	opts = append(opts, verbio_speech_center.WithGrammarType(verbio_speech_center.GrammarType(b.cmd.GrammarType)))
+	connection, err := globalOpts.connectionOptions()
+	if err != nil {
+		log.Logger.Fatalf("%v", err)
+	}
+	opts = append(opts, connection...)
+	opts = append(opts, verbio_speech_center.WithReconnect(verbio_speech_center.ReconnectPolicy{
+		MaxReconnects: b.cmd.Reconnects,
+		Backoff:       b.cmd.ReconnectDelay,
+	}))
+
+	recogniser, err := verbio_speech_center.NewRecogniser(b.url, b.tokenFile, opts...)
+	log.Logger.Infof("Created recogniser")
+	if err != nil {
+		log.Logger.Fatalf("Error creating recogniser: %+v", err)
+	}
+	defer recogniser.Close()
+
+	stateFile := b.cmd.StateFile
+	if stateFile == "" {
+		stateFile = filepath.Join(b.cmd.OutputDir, batchStateFileName)
+	}
+	subtitleOptions := verbio_speech_center.SubtitleOptions{
+		MaxLineLength:  b.cmd.MaxLineLength,
+		MaxCueDuration: b.cmd.MaxCueDuration,
+		MinPause:       b.cmd.MinPause,
+	}
+
+	summary, err := recogniser.RecogniseBatch(ctx, items, verbio_speech_center.BatchOptions{
+		Workers:     b.cmd.Workers,
+		Recognition: recognition,
+		StateFile:   stateFile,
+		OnResult: func(item verbio_speech_center.BatchItem, transcript *verbio_speech_center.Transcript) error {
+			return writeBatchResult(outputs[item.Audio], transcript, b.cmd.OutputFormat, subtitleOptions)
+		},
+	})
+	if summary == nil {
+		log.Logger.Fatalf("Error in batch recognition: %+v", err)
+	}
+	if writeErr := writeBatchSummary(filepath.Join(b.cmd.OutputDir, batchSummaryFileName), summary); writeErr != nil {
+		log.Logger.Errorf("Error writing summary: %+v", writeErr)
+	}
+
+	log.Logger.Infof("Batch finished in %s: %d completed, %d skipped, %d failed out of %d",
+		summary.Elapsed.Round(time.Millisecond), summary.Completed, summary.Skipped, summary.Failed, summary.Total)
+	for _, failure := range summary.Failures {
+		log.Logger.Errorf("Failed %s: %s", failure.Item.Audio, failure.Error)
+	}
+	if err != nil {
+		log.Logger.Fatalf("Batch interrupted: %+v", err)
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d files failed", summary.Failed, summary.Total)
+	}
+	return nil
+}
+
+// validateBatch checks the recognition options every item of a batch would
+// be recognised with, so that mistakes surface before any audio is sent.
+func validateBatch(items []verbio_speech_center.BatchItem, recognition verbio_speech_center.RecognitionOptions) error {
+	for _, item := range items {
+		recognition.Grammar = item.Grammar
+		recognition.Topic = item.Topic
+		recognition.Language = item.Language
+		if err := recognition.Validate(); err != nil {
+			return fmt.Errorf("invalid recognition options for %s: %w", item.Audio, err)
+		}
+	}
+	return nil
+}
+
+// outputFiles names the result file of every item after its audio file, and
+// refuses batches where two items would write to the same file.
+func (b *BatchRecognizeCommand) outputFiles(items []verbio_speech_center.BatchItem) (map[string]string, error) {
+	extension := ".txt"
+	if b.cmd.OutputFormat != "text" {
+		extension = "." + b.cmd.OutputFormat
+	}
+
+	outputs := make(map[string]string, len(items))
+	sources := make(map[string]string, len(items))
+	for _, item := range items {
+		if _, ok := outputs[item.Audio]; ok {
+			return nil, fmt.Errorf("%s appears more than once in the batch", item.Audio)
+		}
+		name := strings.TrimSuffix(filepath.Base(item.Audio), filepath.Ext(item.Audio)) + extension
+		output := filepath.Join(b.cmd.OutputDir, name)
+		if source, ok := sources[output]; ok {
+			return nil, fmt.Errorf("%s and %s would both be written to %s", source, item.Audio, output)
+		}
+		outputs[item.Audio] = output
+		sources[output] = item.Audio
+	}
+	return outputs, nil
+}
+
+func writeBatchResult(file string, transcript *verbio_speech_center.Transcript, format string, options verbio_speech_center.SubtitleOptions) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := writeTranscript(f, transcript, format, options); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Logger.Infof("Result written to %s", file)
+	return nil
+}
+
+func writeBatchSummary(file string, summary *verbio_speech_center.BatchSummary) error {
+	contents, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(contents, '\n'), 0644)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"verbio_speech_center"
+	"verbio_speech_center/log"
+)
+
+type EvaluateOpts struct {
+	Input           string   `short:"i" long:"input" description:"Directory, glob pattern, or JSONL/CSV manifest of the audio files to evaluate" required:"true"`
+	References      string   `short:"r" long:"references" description:"Directory holding a <name>.txt reference for every audio file that has none in the manifest (default: next to each audio file)"`
+	Workers         int      `short:"j" long:"workers" description:"Number of recognitions run at the same time" default:"4"`
+	Grammar         string   `short:"g" long:"grammar" description:"Path or URI of the grammar used for files that set neither grammar nor topic"`
+	GrammarType     string   `long:"grammar-type" description:"How the grammar is sent: auto picks a URI, inline ABNF/GRXML source or a compiled grammar from the value and the file contents" choice:"auto" choice:"compiled" choice:"inline" choice:"uri" default:"auto"`
+	Topic           string   `short:"T" long:"topic" description:"Topic used for files that set neither grammar nor topic"`
+	Language        string   `short:"L" long:"language" description:"Language used for files that do not set one" default:"en-US"`
+	WordBoosting    []string `short:"w" long:"word-boosting" description:"Word to boost during recognition (can be specified multiple times)"`
+	TargetRate      int      `long:"target-rate" description:"Convert the audio to 16-bit mono LPCM at this sample rate (8000 or 16000) before recognition"`
+	KeepCase        bool     `long:"keep-case" description:"Compare texts without lowercasing them"`
+	KeepPunctuation bool     `long:"keep-punctuation" description:"Compare texts without removing punctuation"`
+	Rules           string   `long:"rules" description:"File of normalization rules, one \"pattern => replacement\" per line, applied to both texts"`
+	Confusions      int      `long:"confusions" description:"Number of most frequent confusions listed in the text report" default:"10"`
+	ReportFormat    string   `long:"report-format" description:"Format of the report" choice:"text" choice:"json" default:"text"`
+	Output          string   `short:"o" long:"output" description:"File the report is written to (default: standard output)"`
+	Speed           float64  `long:"speed" description:"Streaming speed as a multiple of real time (0 sends the audio unthrottled)" default:"1"`
+	ChunkMs         int      `long:"chunk-ms" description:"Milliseconds of audio sent in each request" default:"50"`
+	RecognitionFlags
+}
+
+type EvaluateCommand struct {
+	url       string
+	tokenFile string
+	cmd       *EvaluateOpts
+}
+
+func NewEvaluateCommand(url, tokenFile string, cmd *EvaluateOpts) Command {
+	return &EvaluateCommand{
+		url:       url,
+		tokenFile: tokenFile,
+		cmd:       cmd,
+	}
+}
+
+func (e *EvaluateCommand) Execute(ctx context.Context) error {
+	items, err := verbio_speech_center.LoadBatch(e.cmd.Input, verbio_speech_center.BatchItem{
+		Language: e.cmd.Language,
+		Topic:    e.cmd.Topic,
+		Grammar:  e.cmd.Grammar,
+	})
+	if err != nil {
+		log.Logger.Fatalf("Error loading batch: %+v", err)
+	}
+	recognition, err := e.cmd.options("", "", "", e.cmd.WordBoosting)
+	if err != nil {
+		log.Logger.Fatalf("Error loading word boosting list: %+v", err)
+	}
+	if err := validateBatch(items, recognition); err != nil {
+		log.Logger.Fatalf("%v", err)
+	}
+	for i := range items {
+		if items[i].Reference, err = e.reference(items[i]); err != nil {
+			log.Logger.Fatalf("%v", err)
+		}
+	}
+
+	normalization := verbio_speech_center.NormalizationOptions{
+		KeepCase:        e.cmd.KeepCase,
+		KeepPunctuation: e.cmd.KeepPunctuation,
+	}
+	if e.cmd.Rules != "" {
+		if normalization.Rules, err = verbio_speech_center.LoadNormalizationRules(e.cmd.Rules); err != nil {
+			log.Logger.Fatalf("%v", err)
+		}
+	}
+
+	opts := []verbio_speech_center.Option{
+		verbio_speech_center.WithPacing(verbio_speech_center.Pacing{
+			Speed:         e.cmd.Speed,
+			ChunkDuration: time.Duration(e.cmd.ChunkMs) * time.Millisecond,
+		}),
+	}
+	if e.cmd.TargetRate != 0 {
+		opts = append(opts, verbio_speech_center.WithTargetSampleRate(e.cmd.TargetRate))
+	}
+	opts = append(opts, verbio_speech_center.WithGrammarType(verbio_speech_center.GrammarType(e.cmd.GrammarType)))
+	connection, err := globalOpts.connectionOptions()
+	if err != nil {
+		log.Logger.Fatalf("%v", err)
+	}
+	opts = append(opts, connection...)
+
+	recogniser, err := verbio_speech_center.NewRecogniser(e.url, e.tokenFile, opts...)
+	log.Logger.Infof("Created recogniser")
+	if err != nil {
+		log.Logger.Fatalf("Error creating recogniser: %+v", err)
+	}
+	defer recogniser.Close()
+
+	var mu sync.Mutex
+	scores := make([]verbio_speech_center.FileScore, 0, len(items))
+	summary, err := recogniser.RecogniseBatch(ctx, items, verbio_speech_center.BatchOptions{
+		Workers:     e.cmd.Workers,
+		Recognition: recognition,
+		OnResult: func(item verbio_speech_center.BatchItem, transcript *verbio_speech_center.Transcript) error {
+			score := verbio_speech_center.ScoreTranscript(item.Reference, transcript.Text(), normalization)
+			log.Logger.Infof("Scored %s [wer=%.2f%%] [cer=%.2f%%]", item.Audio, 100*score.WER, 100*score.CER)
+			mu.Lock()
+			defer mu.Unlock()
+			scores = append(scores, verbio_speech_center.FileScore{Name: item.Audio, Score: score})
+			return nil
+		},
+	})
+	if summary == nil {
+		log.Logger.Fatalf("Error in batch recognition: %+v", err)
+	}
+	if err != nil {
+		log.Logger.Fatalf("Evaluation interrupted: %+v", err)
+	}
+	for _, failure := range summary.Failures {
+		log.Logger.Errorf("Failed %s: %s", failure.Item.Audio, failure.Error)
+	}
+
+	// Workers finish in any order; report the files in the order of the batch.
+	position := make(map[string]int, len(items))
+	for i, item := range items {
+		position[item.Audio] = i
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return position[scores[i].Name] < position[scores[j].Name]
+	})
+	evaluation := verbio_speech_center.NewEvaluation(scores)
+
+	if err := e.writeReport(evaluation); err != nil {
+		log.Logger.Fatalf("Error writing report: %+v", err)
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d files failed and were left out of the scores", summary.Failed, summary.Total)
+	}
+	return nil
+}
+
+// reference returns the reference transcript of item, read from a .txt file
+// named after the audio file when the manifest does not give one.
+func (e *EvaluateCommand) reference(item verbio_speech_center.BatchItem) (string, error) {
+	if item.Reference != "" {
+		return item.Reference, nil
+	}
+
+	dir := filepath.Dir(item.Audio)
+	if e.cmd.References != "" {
+		dir = e.cmd.References
+	}
+	name := strings.TrimSuffix(filepath.Base(item.Audio), filepath.Ext(item.Audio)) + ".txt"
+	contents, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("no reference for %s: expected %s", item.Audio, filepath.Join(dir, name))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading reference for %s: %w", item.Audio, err)
+	}
+	return string(contents), nil
+}
+
+func (e *EvaluateCommand) writeReport(evaluation *verbio_speech_center.Evaluation) error {
+	out := os.Stdout
+	if e.cmd.Output != "" {
+		f, err := os.Create(e.cmd.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	var err error
+	if e.cmd.ReportFormat == "json" {
+		err = writeEvaluationJSON(out, evaluation)
+	} else {
+		err = writeEvaluationText(out, evaluation, e.cmd.Confusions)
+	}
+	if err != nil {
+		return err
+	}
+	if e.cmd.Output != "" {
+		log.Logger.Infof("Report written to %s", e.cmd.Output)
+	}
+	return nil
+}
+
+func writeEvaluationJSON(out io.Writer, evaluation *verbio_speech_center.Evaluation) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(evaluation)
+}
+
+// writeEvaluationText writes a table of per-file scores followed by the
+// totals and the confusions most often made.
+func writeEvaluationText(out io.Writer, evaluation *verbio_speech_center.Evaluation, confusions int) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tWER\tCER\tSUB\tINS\tDEL\tWORDS\t")
+	for _, file := range evaluation.Files {
+		writeScoreRow(w, file.Name, file.WER, file.CER, file.Words)
+	}
+	writeScoreRow(w, "TOTAL", evaluation.WER, evaluation.CER, evaluation.Words)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if confusions <= 0 || len(evaluation.Confusions) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Most frequent confusions:")
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, confusion := range evaluation.Confusions[:min(confusions, len(evaluation.Confusions))] {
+		fmt.Fprintf(w, "  %d\t%s\t->\t%s\n", confusion.Count, orNone(confusion.Reference), orNone(confusion.Hypothesis))
+	}
+	return w.Flush()
+}
+
+func writeScoreRow(w io.Writer, name string, wer, cer float64, words verbio_speech_center.ErrorCounts) {
+	fmt.Fprintf(w, "%s\t%.2f%%\t%.2f%%\t%d\t%d\t%d\t%d\t\n",
+		name, 100*wer, 100*cer, words.Substitutions, words.Insertions, words.Deletions, words.ReferenceLength)
+}
+
+// orNone stands in for the missing side of an insertion or a deletion.
+func orNone(word string) string {
+	if word == "" {
+		return "(none)"
+	}
+	return word
+}
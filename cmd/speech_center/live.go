@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"verbio_speech_center"
+)
+
+// liveLineWidth bounds interim hypotheses so that redrawing them with a
+// carriage return never wraps onto a second terminal line.
+const liveLineWidth = 79
+
+// liveDisplay redraws the current interim hypothesis in place and moves on to
+// a new line each time a hypothesis becomes final.
+type liveDisplay struct {
+	out io.Writer
+}
+
+func newLiveDisplay(out io.Writer) *liveDisplay {
+	return &liveDisplay{out: out}
+}
+
+func (l *liveDisplay) handle(event verbio_speech_center.RecognitionEvent) {
+	if event.IsFinal {
+		fmt.Fprintf(l.out, "\r\033[K%s\n", event.Text)
+		return
+	}
+	fmt.Fprintf(l.out, "\r\033[K%s", tail(event.Text, liveLineWidth))
+}
+
+// tail returns the last width runes of text, marking the cut with an ellipsis.
+func tail(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 	"verbio_speech_center"
 	"verbio_speech_center/constants"
 	"verbio_speech_center/log"
 	ttsv1 "verbio_speech_center/proto/speechcenter/tts"
 
 	"github.com/jessevdk/go-flags"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -15,21 +24,144 @@ const (
 )
 
 type Command interface {
-	Execute() error
+	Execute(ctx context.Context) error
 }
 
 type GlobalOpts struct {
-	LogLevel  string `short:"l" long:"log-level" description:"Log Level (must be one of TRACE DEBUG INFO WARN ERROR)" default:"info"`
-	TokenFile string `short:"t" long:"token-file" description:"Path to the Token File" `
-	Url       string `short:"u" long:"url" description:"Url of the service" default:""`
+	LogLevel  string        `short:"l" long:"log-level" description:"Log Level (must be one of TRACE DEBUG INFO WARN ERROR)" default:"info"`
+	TokenFile string        `short:"t" long:"token-file" description:"Path to the Token File" `
+	Url       string        `short:"u" long:"url" description:"Url of the service" default:""`
+	Timeout   time.Duration `long:"timeout" description:"Abort the command if it has not finished after this long (e.g. 30s, 5m)"`
+	AuthFlags
+	RetryFlags
+}
+
+// connectionOptions builds the client options shared by every command that
+// connects to the service.
+func (g *GlobalOpts) connectionOptions() ([]verbio_speech_center.Option, error) {
+	retry, err := g.RetryFlags.option()
+	if err != nil {
+		return nil, err
+	}
+	opts := []verbio_speech_center.Option{retry}
+	if g.ClientID != "" {
+		opts = append(opts, g.AuthFlags.option())
+	}
+	return opts, nil
+}
+
+// AuthFlags authenticate with client credentials instead of a token file.
+type AuthFlags struct {
+	ClientID      string        `long:"client-id" description:"OAuth client ID; tokens are then fetched from --token-url and refreshed before they expire" env:"SPEECH_CENTER_CLIENT_ID"`
+	ClientSecret  string        `long:"client-secret" description:"OAuth client secret" env:"SPEECH_CENTER_CLIENT_SECRET"`
+	TokenURL      string        `long:"token-url" description:"Token endpoint the client credentials are exchanged at" env:"SPEECH_CENTER_TOKEN_URL"`
+	Scopes        []string      `long:"scope" description:"OAuth scope requested with the client credentials (can be specified multiple times)"`
+	RefreshMargin time.Duration `long:"token-refresh-margin" description:"Replace a token this long before it expires" default:"1m"`
+	TokenTimeout  time.Duration `long:"token-timeout" description:"Give up on a request to the token endpoint after this long" default:"30s"`
+}
+
+func (f *AuthFlags) option() verbio_speech_center.Option {
+	return verbio_speech_center.WithClientCredentials(verbio_speech_center.ClientCredentials{
+		ClientID:      f.ClientID,
+		ClientSecret:  f.ClientSecret,
+		TokenURL:      f.TokenURL,
+		Scopes:        f.Scopes,
+		RefreshMargin: f.RefreshMargin,
+		Timeout:       f.TokenTimeout,
+	})
+}
+
+// RetryFlags configure how requests failing with a transient status are
+// started again.
+type RetryFlags struct {
+	RetryAttempts   int           `long:"retry-attempts" description:"Times a request failing with a retryable status is tried, the first one included" default:"1"`
+	RetryBackoff    time.Duration `long:"retry-backoff" description:"Wait before the first retry, doubled for every further one" default:"500ms"`
+	RetryMaxBackoff time.Duration `long:"retry-max-backoff" description:"Longest wait between two attempts" default:"10s"`
+	RetryJitter     float64       `long:"retry-jitter" description:"Fraction of every wait added or taken at random" default:"0.2"`
+	RetryBudget     time.Duration `long:"retry-budget" description:"Do not start a new attempt once this long has passed since the first one"`
+	RetryCodes      []string      `long:"retry-code" description:"gRPC status retried or reconnected after, e.g. UNAVAILABLE (can be specified multiple times; default: UNAVAILABLE and RESOURCE_EXHAUSTED)"`
+}
+
+// option builds the retry policy of the flags.
+func (f *RetryFlags) option() (verbio_speech_center.Option, error) {
+	policy := verbio_speech_center.RetryPolicy{
+		MaxAttempts:    f.RetryAttempts,
+		InitialBackoff: f.RetryBackoff,
+		MaxBackoff:     f.RetryMaxBackoff,
+		Jitter:         f.RetryJitter,
+		Budget:         f.RetryBudget,
+	}
+	for _, name := range f.RetryCodes {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil {
+			return nil, fmt.Errorf("invalid retry code %s", name)
+		}
+		policy.Codes = append(policy.Codes, code)
+	}
+	return verbio_speech_center.WithRetry(policy), nil
+}
+
+// RecognitionFlags are the recognition settings shared by every command that
+// recognises audio.
+type RecognitionFlags struct {
+	Formatting    bool     `long:"formatting" description:"Ask the service to format the transcript"`
+	Diarization   bool     `long:"diarization" description:"Ask the service to tell speakers apart"`
+	Channels      uint16   `long:"channels" description:"Number of audio channels; must match the audio when it has a header (default: taken from the audio, mono for headerless audio)"`
+	Labels        []string `long:"label" description:"Label attached to the recognition request (can be specified multiple times)"`
+	ConfigVersion string   `long:"config-version" description:"Version of the recognition configuration" choice:"V1" choice:"V2" default:"V2"`
+	BoostingFile  string   `long:"word-boosting-file" description:"Text, CSV or JSON file of words to boost, with optional weights and per-language sections"`
+}
+
+// options combines the flags with the settings that every command declares
+// on its own, loading the word boosting list if one was given.
+func (f *RecognitionFlags) options(grammar, topic, language string, wordBoosting []string) (verbio_speech_center.RecognitionOptions, error) {
+	options := verbio_speech_center.RecognitionOptions{
+		Grammar:           grammar,
+		Topic:             topic,
+		Language:          language,
+		WordBoosting:      wordBoosting,
+		EnableFormatting:  f.Formatting,
+		EnableDiarization: f.Diarization,
+		Channels:          f.Channels,
+		Labels:            f.Labels,
+		Version:           f.ConfigVersion,
+	}
+	if f.BoostingFile != "" {
+		list, err := verbio_speech_center.LoadBoostingList(f.BoostingFile)
+		if err != nil {
+			return options, err
+		}
+		options.BoostingList = list
+	}
+	return options, nil
 }
 
 type RecognizeOpts struct {
-	Audio        string   `short:"a" long:"audio" description:"Audio file to be sent" required:"true"`
-	Grammar      string   `short:"g" long:"grammar" description:"Path to the grammar to be used"`
-	Topic        string   `short:"T" long:"topic" description:"Topic to be used"`
-	Language     string   `short:"L" long:"language" description:"Language to be used" default:"en-US"`
-	WordBoosting []string `short:"w" long:"word-boosting" description:"Word to boost during recognition (can be specified multiple times)"`
+	Audio          string        `short:"a" long:"audio" description:"Audio file to be sent (WAV, FLAC or Ogg FLAC), or - to stream it from standard input" required:"true"`
+	Grammar        string        `short:"g" long:"grammar" description:"Path or URI of the grammar to be used"`
+	GrammarType    string        `long:"grammar-type" description:"How the grammar is sent: auto picks a URI, inline ABNF/GRXML source or a compiled grammar from the value and the file contents" choice:"auto" choice:"compiled" choice:"inline" choice:"uri" default:"auto"`
+	Topic          string        `short:"T" long:"topic" description:"Topic to be used"`
+	Language       string        `short:"L" long:"language" description:"Language to be used" default:"en-US"`
+	WordBoosting   []string      `short:"w" long:"word-boosting" description:"Word to boost during recognition (can be specified multiple times)"`
+	TargetRate     int           `long:"target-rate" description:"Convert the audio to 16-bit mono LPCM at this sample rate (8000 or 16000) before recognition"`
+	SplitChannels  bool          `long:"split-channels" description:"Recognise each channel of a multi-channel file as a separate stream and print the merged dialogue"`
+	SampleRate     uint32        `long:"sample-rate" description:"Sample rate of headerless audio, read from standard input or from the audio file"`
+	Encoding       string        `long:"encoding" description:"Encoding of the audio: G.711 mu-law and A-law are decoded to LPCM before streaming (defaults to the WAV format tag, or pcm for headerless audio)" choice:"pcm" choice:"mulaw" choice:"alaw"`
+	Live           bool          `long:"live" description:"Print interim hypotheses as they arrive, redrawing the current one in place"`
+	OutputFormat   string        `long:"output-format" description:"Format of the recognition result" choice:"text" choice:"srt" choice:"vtt" default:"text"`
+	Output         string        `short:"o" long:"output" description:"File the recognition result is written to"`
+	MaxLineLength  int           `long:"max-line-length" description:"Maximum number of characters of a subtitle line" default:"42"`
+	MaxCueDuration time.Duration `long:"max-cue-duration" description:"Maximum time a subtitle cue stays on screen" default:"6s"`
+	MinPause       time.Duration `long:"min-pause" description:"Pause between two words that starts a new subtitle cue" default:"700ms"`
+	Speed          float64       `long:"speed" description:"Streaming speed as a multiple of real time (0 sends the audio unthrottled)" default:"1"`
+	ChunkMs        int           `long:"chunk-ms" description:"Milliseconds of audio sent in each request" default:"50"`
+	Reconnects     int           `long:"reconnects" description:"Times a failed stream is reopened, resuming after the last final result"`
+	ReconnectDelay time.Duration `long:"reconnect-backoff" description:"Wait before the first reconnection, doubled for every further one" default:"1s"`
+	EouSilenceMs   int           `long:"eou-silence-ms" description:"Stop streaming once a final result has been received and this many milliseconds of silence follow the last word"`
+	Segment        time.Duration `long:"segment" description:"Cut long audio into segments of at most this length at silences and recognise them in parallel (e.g. 5m)"`
+	SegmentOverlap time.Duration `long:"segment-overlap" description:"Audio around each cut recognised by both neighbouring segments" default:"2s"`
+	SegmentWorkers int           `long:"segment-workers" description:"Number of segments recognised at the same time" default:"4"`
+	RecognitionFlags
 }
 
 type SynthesizeOpts struct {
@@ -54,27 +186,177 @@ func NewRecognizeCommand(url, tokenFile string, cmd *RecognizeOpts) Command {
 	}
 }
 
-func (r *RecognizeCommand) Execute() error {
-	recogniser, err := verbio_speech_center.NewRecogniser(r.url, r.tokenFile)
+func (r *RecognizeCommand) Execute(ctx context.Context) error {
+	recognition, err := r.cmd.options(r.cmd.Grammar, r.cmd.Topic, r.cmd.Language, r.cmd.WordBoosting)
+	if err != nil {
+		log.Logger.Fatalf("Error loading word boosting list: %+v", err)
+	}
+	recognition.EndOfUtteranceSilence = time.Duration(r.cmd.EouSilenceMs) * time.Millisecond
+	if err := recognition.Validate(); err != nil {
+		log.Logger.Fatalf("Invalid recognition options: %v", err)
+	}
+
+	opts := []verbio_speech_center.Option{
+		verbio_speech_center.WithPacing(verbio_speech_center.Pacing{
+			Speed:         r.cmd.Speed,
+			ChunkDuration: time.Duration(r.cmd.ChunkMs) * time.Millisecond,
+		}),
+	}
+	if r.cmd.TargetRate != 0 {
+		opts = append(opts, verbio_speech_center.WithTargetSampleRate(r.cmd.TargetRate))
+	}
+	opts = append(opts, verbio_speech_center.WithGrammarType(verbio_speech_center.GrammarType(r.cmd.GrammarType)))
+	connection, err := globalOpts.connectionOptions()
+	if err != nil {
+		log.Logger.Fatalf("%v", err)
+	}
+	opts = append(opts, connection...)
+	opts = append(opts, verbio_speech_center.WithReconnect(verbio_speech_center.ReconnectPolicy{
+		MaxReconnects: r.cmd.Reconnects,
+		Backoff:       r.cmd.ReconnectDelay,
+	}))
+
+	recogniser, err := verbio_speech_center.NewRecogniser(r.url, r.tokenFile, opts...)
 	log.Logger.Infof("Created recogniser")
 	if err != nil {
 		log.Logger.Fatalf("Error creating recogniser: %+v", err)
 	}
 	defer recogniser.Close()
 
-	var res string
-	if r.cmd.Grammar != "" {
-		res, err = recogniser.RecogniseWithGrammar(r.cmd.Audio, r.cmd.Grammar, r.cmd.Language, r.cmd.WordBoosting)
-	} else if r.cmd.Topic != "" {
-		res, err = recogniser.RecogniseWithTopic(r.cmd.Audio, r.cmd.Topic, r.cmd.Language, r.cmd.WordBoosting)
+	if r.cmd.SplitChannels {
+		if r.cmd.OutputFormat != "text" || r.cmd.Output != "" {
+			log.Logger.Fatal("--split-channels cannot be combined with --output-format or --output")
+		}
+		if r.cmd.EouSilenceMs != 0 {
+			log.Logger.Fatal("--split-channels cannot be combined with --eou-silence-ms")
+		}
+		if r.cmd.Segment != 0 {
+			log.Logger.Fatal("--split-channels cannot be combined with --segment")
+		}
+		return r.executeChannels(ctx, recogniser, recognition)
+	}
+
+	useStream := r.cmd.Audio == "-" || r.cmd.Live || r.cmd.SampleRate != 0 || r.cmd.Encoding != ""
+	if r.cmd.Segment != 0 {
+		if useStream {
+			log.Logger.Fatal("--segment cannot be combined with --live, --sample-rate, --encoding or audio read from standard input")
+		}
+		if r.cmd.EouSilenceMs != 0 {
+			log.Logger.Fatal("--segment cannot be combined with --eou-silence-ms")
+		}
+	}
+
+	var res *verbio_speech_center.Transcript
+	if r.cmd.Segment != 0 {
+		res, err = recogniser.RecogniseLong(ctx, r.cmd.Audio, recognition, verbio_speech_center.SegmentationOptions{
+			SegmentDuration: r.cmd.Segment,
+			Overlap:         r.cmd.SegmentOverlap,
+			Workers:         r.cmd.SegmentWorkers,
+		})
+	} else if useStream {
+		res, err = r.executeStream(ctx, recogniser, recognition)
 	} else {
-		log.Logger.Fatal("Either a grammar or a topic must be specified for recognition")
+		res, err = recogniser.Recognise(ctx, r.cmd.Audio, recognition)
+	}
+	if err != nil {
+		log.Logger.Fatalf("Error in recognition: %+v", err)
+	}
+	for _, event := range res.Reconnects {
+		log.Logger.Warnf("Reconnected after a stream failure, resuming %s into the audio: %s", event.Offset, event.Error)
+	}
+	if res.StoppedAt > 0 {
+		log.Logger.Infof("Streaming stopped at the end of the utterance, %s into the audio", res.StoppedAt)
+	}
+
+	if err := r.writeResult(res); err != nil {
+		log.Logger.Fatalf("Error writing result: %+v", err)
+	}
+	return nil
+}
+
+func (r *RecognizeCommand) writeResult(transcript *verbio_speech_center.Transcript) error {
+	if r.cmd.OutputFormat == "text" && r.cmd.Output == "" {
+		log.Logger.Infof("Result: %s", transcript.Text())
+		return nil
+	}
+
+	out := os.Stdout
+	if r.cmd.Output != "" {
+		f, err := os.Create(r.cmd.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := writeTranscript(out, transcript, r.cmd.OutputFormat, r.subtitleOptions()); err != nil {
+		return err
+	}
+
+	log.Logger.Infof("Result written to %s", out.Name())
+	return nil
+}
+
+func (r *RecognizeCommand) subtitleOptions() verbio_speech_center.SubtitleOptions {
+	return verbio_speech_center.SubtitleOptions{
+		MaxLineLength:  r.cmd.MaxLineLength,
+		MaxCueDuration: r.cmd.MaxCueDuration,
+		MinPause:       r.cmd.MinPause,
+	}
+}
+
+// writeTranscript writes transcript to out as plain text, SRT or WebVTT.
+func writeTranscript(out io.Writer, transcript *verbio_speech_center.Transcript, format string, options verbio_speech_center.SubtitleOptions) error {
+	switch format {
+	case "srt":
+		return verbio_speech_center.WriteSRT(out, verbio_speech_center.BuildCues(transcript, options))
+	case "vtt":
+		return verbio_speech_center.WriteVTT(out, verbio_speech_center.BuildCues(transcript, options))
+	default:
+		_, err := fmt.Fprintln(out, transcript.Text())
+		return err
+	}
+}
+
+func (r *RecognizeCommand) executeStream(ctx context.Context, recogniser *verbio_speech_center.Recogniser, recognition verbio_speech_center.RecognitionOptions) (*verbio_speech_center.Transcript, error) {
+	if r.cmd.TargetRate != 0 {
+		log.Logger.Fatal("--target-rate cannot be combined with --live, --sample-rate, --encoding or audio read from standard input")
+	}
+
+	audio := os.Stdin
+	if r.cmd.Audio != "-" {
+		f, err := os.Open(r.cmd.Audio)
+		if err != nil {
+			log.Logger.Fatalf("Error opening audio file: %+v", err)
+		}
+		defer f.Close()
+		audio = f
+	}
+
+	config := verbio_speech_center.StreamConfig{
+		RecognitionOptions: recognition,
+		SampleRate:         r.cmd.SampleRate,
+		Encoding:           verbio_speech_center.AudioEncoding(r.cmd.Encoding),
+	}
+	if r.cmd.Live {
+		config.OnResult = newLiveDisplay(os.Stdout).handle
 	}
+	return recogniser.RecogniseStreamTranscript(ctx, audio, config)
+}
+
+func (r *RecognizeCommand) executeChannels(ctx context.Context, recogniser *verbio_speech_center.Recogniser, recognition verbio_speech_center.RecognitionOptions) error {
+	res, err := recogniser.RecogniseChannels(ctx, r.cmd.Audio, recognition)
 	if err != nil {
 		log.Logger.Fatalf("Error in recognition: %+v", err)
 	}
 
-	log.Logger.Infof("Result: %s", res)
+	for channel, transcript := range res.Channels {
+		log.Logger.Infof("Result [channel=%d]: %s", channel, transcript)
+	}
+	for _, turn := range res.Dialogue {
+		log.Logger.Infof("[%s - %s] channel %d: %s", turn.Start, turn.End, turn.Channel, turn.Text)
+	}
 	return nil
 }
 
@@ -114,8 +396,12 @@ func parseSamplingRate(rate string) (ttsv1.VoiceSamplingRate, error) {
 	}
 }
 
-func (s *SynthesizeCommand) Execute() error {
-	synthesizer, err := verbio_speech_center.NewSynthesizer(s.url, s.tokenFile)
+func (s *SynthesizeCommand) Execute(ctx context.Context) error {
+	connection, err := globalOpts.connectionOptions()
+	if err != nil {
+		log.Logger.Fatalf("%v", err)
+	}
+	synthesizer, err := verbio_speech_center.NewSynthesizer(s.url, s.tokenFile, connection...)
 	log.Logger.Infof("Created synthesizer")
 	if err != nil {
 		log.Logger.Fatalf("Error creating synthesizer: %+v", err)
@@ -136,7 +422,7 @@ func (s *SynthesizeCommand) Execute() error {
 		log.Logger.Fatalf("%v", err)
 	}
 
-	err = synthesizer.StreamingSynthesizeSpeech(s.cmd.Text, s.cmd.Voice, samplingRate, format, s.cmd.Output)
+	err = synthesizer.StreamingSynthesizeSpeech(ctx, s.cmd.Text, s.cmd.Voice, samplingRate, format, s.cmd.Output)
 	if err != nil {
 		log.Logger.Fatalf("Error in synthesis: %+v", err)
 	}
@@ -155,12 +441,34 @@ func main() {
 		log.Logger.Fatalf("Failed to add 'recognize' command: %+v", err)
 	}
 
+	batchRecognizeCmd := BatchRecognizeOpts{}
+	_, err = parser.AddCommand("batch-recognize", "Recognize speech from many audio files", "Recognize speech from a directory, glob or manifest of audio files, writing one result per file and a summary", &batchRecognizeCmd)
+	if err != nil {
+		log.Logger.Fatalf("Failed to add 'batch-recognize' command: %+v", err)
+	}
+
+	evaluateCmd := EvaluateOpts{}
+	_, err = parser.AddCommand("evaluate", "Measure recognition accuracy", "Recognize audio files with known reference transcripts and report their word and character error rates", &evaluateCmd)
+	if err != nil {
+		log.Logger.Fatalf("Failed to add 'evaluate' command: %+v", err)
+	}
+
 	synthesizeCmd := SynthesizeOpts{}
 	_, err = parser.AddCommand("synthesize", "Synthesize speech from text", "Synthesize speech from text to audio file", &synthesizeCmd)
 	if err != nil {
 		log.Logger.Fatalf("Failed to add 'synthesize' command: %+v", err)
 	}
 
+	tokenCmd, err := parser.AddCommand("token", "Work with access tokens", "Work with the access token of the token file", &struct{}{})
+	if err != nil {
+		log.Logger.Fatalf("Failed to add 'token' command: %+v", err)
+	}
+	tokenInspectCmd := TokenInspectOpts{}
+	_, err = tokenCmd.AddCommand("inspect", "Show the claims of the token", "Decode the JWT token of the token file and show who it was issued to and when it expires", &tokenInspectCmd)
+	if err != nil {
+		log.Logger.Fatalf("Failed to add 'token inspect' command: %+v", err)
+	}
+
 	_, err = parser.Parse()
 	if err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok {
@@ -173,14 +481,17 @@ func main() {
 
 	if parser.Active == nil {
 		parser.WriteHelp(nil)
-		log.Logger.Fatal("No command specified. Use 'recognize' or 'synthesize'")
+		log.Logger.Fatal("No command specified. Use 'recognize', 'batch-recognize', 'evaluate', 'synthesize' or 'token'")
 	}
 
-	if globalOpts.TokenFile == "" {
-		log.Logger.Fatal("Token file is required. Use -t or --token-file")
+	commandName := parser.Active.Name
+	if parser.Active.Active != nil {
+		commandName += " " + parser.Active.Active.Name
 	}
 
-	commandName := parser.Active.Name
+	if globalOpts.TokenFile == "" && (globalOpts.ClientID == "" || commandName == "token inspect") {
+		log.Logger.Fatal("Token file is required. Use -t or --token-file, or --client-id to authenticate with client credentials")
+	}
 
 	log.InitLogger(globalOpts.LogLevel)
 	log.Logger.Infof("Starting %s (%s)", constants.APP_NAME, constants.VERSION)
@@ -196,13 +507,27 @@ func main() {
 	switch commandName {
 	case "recognize":
 		command = NewRecognizeCommand(url, globalOpts.TokenFile, &recognizeCmd)
+	case "batch-recognize":
+		command = NewBatchRecognizeCommand(url, globalOpts.TokenFile, &batchRecognizeCmd)
+	case "evaluate":
+		command = NewEvaluateCommand(url, globalOpts.TokenFile, &evaluateCmd)
 	case "synthesize":
 		command = NewSynthesizeCommand(url, globalOpts.TokenFile, &synthesizeCmd)
+	case "token inspect":
+		command = NewTokenInspectCommand(globalOpts.TokenFile, &tokenInspectCmd)
 	default:
 		log.Logger.Fatalf("Unknown command: %s", commandName)
 	}
 
-	if err := command.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if globalOpts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, globalOpts.Timeout)
+		defer cancel()
+	}
+
+	if err := command.Execute(ctx); err != nil {
 		log.Logger.Fatalf("Command execution failed: %+v", err)
 	}
 }
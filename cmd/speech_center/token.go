@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"verbio_speech_center"
+)
+
+type TokenInspectOpts struct {
+	Format string `long:"format" description:"Format the claims are shown in" choice:"text" choice:"json" default:"text"`
+}
+
+type TokenInspectCommand struct {
+	tokenFile string
+	cmd       *TokenInspectOpts
+}
+
+func NewTokenInspectCommand(tokenFile string, cmd *TokenInspectOpts) Command {
+	return &TokenInspectCommand{
+		tokenFile: tokenFile,
+		cmd:       cmd,
+	}
+}
+
+// Execute prints the claims of the token and fails when it has expired.
+func (t *TokenInspectCommand) Execute(ctx context.Context) error {
+	claims, err := verbio_speech_center.LoadTokenClaims(t.tokenFile)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if t.cmd.Format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(claims.Claims); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Subject:\t%s\n", claims.Subject)
+		fmt.Fprintf(w, "Issuer:\t%s\n", claims.Issuer)
+		fmt.Fprintf(w, "Audience:\t%s\n", strings.Join(claims.Audience, ", "))
+		fmt.Fprintf(w, "Issued at:\t%s\n", formatClaimTime(claims.IssuedAt))
+		fmt.Fprintf(w, "Expires at:\t%s\n", formatClaimTime(claims.ExpiresAt))
+		fmt.Fprintf(w, "Status:\t%s\n", tokenStatus(claims, now))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if claims.Expired(now) {
+		return fmt.Errorf("%w %s ago", verbio_speech_center.ErrTokenExpired, now.Sub(claims.ExpiresAt).Round(time.Second))
+	}
+	return nil
+}
+
+func formatClaimTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func tokenStatus(claims *verbio_speech_center.TokenClaims, now time.Time) string {
+	switch {
+	case claims.ExpiresAt.IsZero():
+		return "valid, never expires"
+	case claims.Expired(now):
+		return fmt.Sprintf("expired %s ago", now.Sub(claims.ExpiresAt).Round(time.Second))
+	default:
+		return fmt.Sprintf("valid for %s", claims.ExpiresAt.Sub(now).Round(time.Second))
+	}
+}
@@ -0,0 +1,201 @@
+package verbio_speech_center
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Errors that the failures of the Recogniser and the Synthesizer match with
+// errors.Is.
+var (
+	// ErrClientClosed is returned by calls made after the client was closed.
+	ErrClientClosed = errors.New("client is closed")
+	// ErrInvalidOptions is a request whose settings were rejected before
+	// anything was sent.
+	ErrInvalidOptions = errors.New("invalid options")
+	// ErrInvalidAudio is audio that could not be read or decoded.
+	ErrInvalidAudio = errors.New("invalid audio")
+	// ErrInvalidGrammar is a grammar that could not be loaded.
+	ErrInvalidGrammar = errors.New("invalid grammar")
+	// ErrInvalidRequest is a request the service rejected as invalid.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrUnauthenticated is a token the service did not accept.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrTokenExpired is a static token past its expiry.
+	ErrTokenExpired = errors.New("token expired")
+	// ErrPermissionDenied is a request the token is not allowed to make.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrQuotaExceeded is a request turned away for going over a quota or
+	// rate limit.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrUnavailable is a service that could not be reached.
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrNoAudio is a synthesis that completed without returning audio.
+	ErrNoAudio = errors.New("received no audio data")
+)
+
+// reasonKinds are the sentinel errors of the failures the service describes
+// with a reason, such as GRAMMAR_COMPILATION_FAILED or AUDIO_DECODING_ERROR,
+// matched by a word the reason contains.
+var reasonKinds = []struct {
+	word string
+	kind error
+}{
+	{"GRAMMAR", ErrInvalidGrammar},
+	{"AUDIO", ErrInvalidAudio},
+	{"QUOTA", ErrQuotaExceeded},
+	{"RATE_LIMIT", ErrQuotaExceeded},
+}
+
+// RecognitionError is a failed recognition. It matches the sentinel error of
+// its kind with errors.Is, and unwraps to its cause.
+type RecognitionError struct {
+	// Kind is the sentinel error the failure matches, or nil.
+	Kind error
+	// Code is the gRPC status of the failure. It is Unknown when the service
+	// reported the failure in a response rather than as a status.
+	Code codes.Code
+	// Reason, Domain and Metadata describe the failure as reported by the
+	// service, when it did.
+	Reason   string
+	Domain   string
+	Metadata map[string]string
+	// Err is the cause of the failure. It is nil when the service reported
+	// the failure in a response.
+	Err error
+}
+
+func (e *RecognitionError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("recognition error: %s (domain: %s)", e.Reason, e.Domain)
+	}
+	return e.Err.Error()
+}
+
+func (e *RecognitionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RecognitionError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// SynthesisError is a failed synthesis. It matches the sentinel error of its
+// kind with errors.Is, and unwraps to its cause.
+type SynthesisError struct {
+	// Kind is the sentinel error the failure matches, or nil.
+	Kind error
+	// Code is the gRPC status of the failure, Unknown if it has none.
+	Code codes.Code
+	// Reason, Domain and Metadata describe the failure as reported by the
+	// service, when it did.
+	Reason   string
+	Domain   string
+	Metadata map[string]string
+	Err      error
+}
+
+func (e *SynthesisError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SynthesisError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SynthesisError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// recognitionError marks err as a recognition failure of the given kind.
+func recognitionError(kind error, err error) error {
+	return &RecognitionError{Kind: kind, Code: codes.Unknown, Err: err}
+}
+
+// audioLoadError marks err, the failure of loadAudio or loadChannels, as
+// invalid audio.
+func audioLoadError(err error) error {
+	return recognitionError(ErrInvalidAudio, fmt.Errorf("error loading audio file: %w", err))
+}
+
+// recognitionFailure classifies err, the failure of a recognition stream, by
+// its gRPC status. Errors already classified are returned as they are.
+func recognitionFailure(err error) error {
+	var classified *RecognitionError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+	failure := &RecognitionError{Code: status.Code(err), Err: err}
+	failure.Reason, failure.Domain, failure.Metadata = statusInfo(err)
+	failure.Kind = statusKind(failure.Code, failure.Reason)
+	return failure
+}
+
+// synthesisError marks err as a synthesis failure of the given kind.
+func synthesisError(kind error, err error) error {
+	return &SynthesisError{Kind: kind, Code: codes.Unknown, Err: err}
+}
+
+// synthesisFailure classifies err, the failure of a synthesis stream, by its
+// gRPC status. Errors already classified are returned as they are.
+func synthesisFailure(err error) error {
+	var classified *SynthesisError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+	failure := &SynthesisError{Code: status.Code(err), Err: err}
+	failure.Reason, failure.Domain, failure.Metadata = statusInfo(err)
+	failure.Kind = statusKind(failure.Code, failure.Reason)
+	return failure
+}
+
+// statusKind returns the sentinel error of a gRPC status code, or that of
+// its reason when the code has none.
+func statusKind(code codes.Code, reason string) error {
+	switch code {
+	case codes.InvalidArgument:
+		return ErrInvalidRequest
+	case codes.Unauthenticated:
+		return ErrUnauthenticated
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.ResourceExhausted:
+		return ErrQuotaExceeded
+	case codes.Unavailable:
+		return ErrUnavailable
+	default:
+		return reasonKind(reason)
+	}
+}
+
+// reasonKind returns the sentinel error of a reason reported by the service,
+// or nil.
+func reasonKind(reason string) error {
+	reason = strings.ToUpper(reason)
+	for _, r := range reasonKinds {
+		if strings.Contains(reason, r.word) {
+			return r.kind
+		}
+	}
+	return nil
+}
+
+// statusInfo returns the ErrorInfo detail of the gRPC status of err, if it
+// has one.
+func statusInfo(err error) (reason, domain string, metadata map[string]string) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return "", "", nil
+	}
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason(), info.GetDomain(), info.GetMetadata()
+		}
+	}
+	return "", "", nil
+}
@@ -0,0 +1,162 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+	ttsv1 "verbio_speech_center/proto/speechcenter/tts"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// erroringServer answers every stream with a recognition error response
+// once it has read the configuration.
+func erroringServer(e *sttv1.RecognitionError) *fakeRecognizerServer {
+	return &fakeRecognizerServer{onConfig: func(_ int, stream recognizeStream) error {
+		return stream.Send(&sttv1.RecognitionStreamingResponse{
+			RecognitionResponse: &sttv1.RecognitionStreamingResponse_Error{Error: e},
+		})
+	}}
+}
+
+func TestRecognitionErrorFromResponse(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, erroringServer(&sttv1.RecognitionError{
+		Reason:   "GRAMMAR_COMPILATION_FAILED",
+		Domain:   "asr",
+		Metadata: map[string]string{"line": "3"},
+	}))
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Topic: "generic"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "recognition error: GRAMMAR_COMPILATION_FAILED (domain: asr)")
+
+	var recognitionErr *RecognitionError
+	assert.True(t, errors.As(err, &recognitionErr))
+	assert.Equal(t, codes.Unknown, recognitionErr.Code)
+	assert.Equal(t, "GRAMMAR_COMPILATION_FAILED", recognitionErr.Reason)
+	assert.Equal(t, "asr", recognitionErr.Domain)
+	assert.Equal(t, "3", recognitionErr.Metadata["line"])
+	assert.True(t, errors.Is(err, ErrInvalidGrammar), "unexpected error: %v", err)
+	assert.False(t, errors.Is(err, ErrInvalidAudio))
+}
+
+func TestReasonKind(t *testing.T) {
+	tests := []struct {
+		reason string
+		kind   error
+	}{
+		{"GRAMMAR_COMPILATION_FAILED", ErrInvalidGrammar},
+		{"audio_decoding_error", ErrInvalidAudio},
+		{"QUOTA_EXCEEDED", ErrQuotaExceeded},
+		{"RATE_LIMITED", ErrQuotaExceeded},
+		{"INTERNAL", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.kind, reasonKind(tt.reason), tt.reason)
+	}
+	assert.Equal(t, ErrUnavailable, statusKind(codes.Unavailable, "AUDIO_DECODING_ERROR"))
+	assert.Equal(t, ErrInvalidAudio, statusKind(codes.FailedPrecondition, "AUDIO_DECODING_ERROR"))
+}
+
+func TestRecognitionErrorFromStatus(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, rejectingServer(1, codes.Unauthenticated, false))
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Topic: "generic"})
+	assert.True(t, errors.Is(err, ErrUnauthenticated), "unexpected error: %v", err)
+	assert.False(t, errors.Is(err, ErrQuotaExceeded))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var recognitionErr *RecognitionError
+	assert.True(t, errors.As(err, &recognitionErr))
+	assert.Equal(t, codes.Unauthenticated, recognitionErr.Code)
+}
+
+func TestRecognitionErrorKinds(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{})
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{})
+	assert.True(t, errors.Is(err, ErrInvalidOptions), "unexpected error: %v", err)
+
+	_, err = recogniser.Recognise(context.Background(), filepath.Join(t.TempDir(), "missing.wav"), RecognitionOptions{Topic: "generic"})
+	assert.True(t, errors.Is(err, ErrInvalidAudio), "unexpected error: %v", err)
+	assert.True(t, errors.Is(err, fs.ErrNotExist), "unexpected error: %v", err)
+
+	_, err = recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Grammar: filepath.Join(t.TempDir(), "missing.gram")})
+	assert.True(t, errors.Is(err, ErrInvalidGrammar), "unexpected error: %v", err)
+}
+
+func TestLoaderErrorKinds(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{})
+	defer recogniser.Close()
+	opts := RecognitionOptions{Topic: "generic"}
+
+	missing := filepath.Join(t.TempDir(), "missing.wav")
+	_, err := recogniser.RecogniseChannels(context.Background(), missing, opts)
+	assert.True(t, errors.Is(err, ErrInvalidAudio), "unexpected error: %v", err)
+	assert.True(t, errors.Is(err, fs.ErrNotExist), "unexpected error: %v", err)
+	assert.True(t, strings.HasPrefix(err.Error(), "error loading audio file: error reading audio file: "), "unexpected error: %v", err)
+
+	_, err = recogniser.RecogniseChannels(context.Background(), spokenWav(t, 1), opts)
+	assert.True(t, errors.Is(err, ErrInvalidAudio), "unexpected error: %v", err)
+	assert.Contains(t, err.Error(), "needs a multi-channel file")
+
+	_, err = recogniser.RecogniseLong(context.Background(), createTemporaryWav(t, 8000, 8, 1, []int{0, 1}), opts, DefaultSegmentation)
+	assert.True(t, errors.Is(err, ErrInvalidAudio), "unexpected error: %v", err)
+	assert.Equal(t, "error loading audio file: unsupported WAV bit depth 8 (only 16-bit LPCM is supported without a target sample rate)", err.Error())
+
+	_, err = recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Grammar: filepath.Join(t.TempDir(), "missing.gram")})
+	assert.True(t, errors.Is(err, ErrInvalidGrammar), "unexpected error: %v", err)
+	assert.True(t, errors.Is(err, fs.ErrNotExist), "unexpected error: %v", err)
+	assert.True(t, strings.HasPrefix(err.Error(), "error loading grammar: error reading grammar file: "), "unexpected error: %v", err)
+}
+
+func TestRecognitionFailureDetails(t *testing.T) {
+	s, err := status.New(codes.ResourceExhausted, "too many requests").WithDetails(&errdetails.ErrorInfo{
+		Reason:   "RATE_LIMITED",
+		Domain:   "speechcenter",
+		Metadata: map[string]string{"limit": "10"},
+	})
+	assert.NoError(t, err)
+
+	failure := recognitionFailure(fmt.Errorf("error sending audio chunk: %w", s.Err()))
+	assert.True(t, errors.Is(failure, ErrQuotaExceeded))
+	var recognitionErr *RecognitionError
+	assert.True(t, errors.As(failure, &recognitionErr))
+	assert.Equal(t, codes.ResourceExhausted, recognitionErr.Code)
+	assert.Equal(t, "RATE_LIMITED", recognitionErr.Reason)
+	assert.Equal(t, "speechcenter", recognitionErr.Domain)
+	assert.Equal(t, "10", recognitionErr.Metadata["limit"])
+	assert.Contains(t, failure.Error(), "error sending audio chunk")
+
+	assert.Equal(t, failure, recognitionFailure(failure))
+	assert.Nil(t, recognitionFailure(nil))
+}
+
+func TestSynthesisErrors(t *testing.T) {
+	synthesizer := newFakeServerSynthesizer(t, rejectingSynthesisServer(1, codes.PermissionDenied))
+	defer synthesizer.Close()
+
+	output := filepath.Join(t.TempDir(), "out.wav")
+	err := synthesizer.StreamingSynthesizeSpeech(context.Background(), "hello", "voice", ttsv1.VoiceSamplingRate_VOICE_SAMPLING_RATE_16KHZ,
+		ttsv1.AudioFormat_AUDIO_FORMAT_WAV_LPCM_S16LE, output)
+	assert.True(t, errors.Is(err, ErrPermissionDenied), "unexpected error: %v", err)
+	var synthesisErr *SynthesisError
+	assert.True(t, errors.As(err, &synthesisErr))
+	assert.Equal(t, codes.PermissionDenied, synthesisErr.Code)
+
+	err = synthesizer.StreamingSynthesizeSpeech(context.Background(), "", "voice", ttsv1.VoiceSamplingRate_VOICE_SAMPLING_RATE_16KHZ,
+		ttsv1.AudioFormat_AUDIO_FORMAT_WAV_LPCM_S16LE, output)
+	assert.True(t, errors.Is(err, ErrInvalidOptions), "unexpected error: %v", err)
+}
@@ -0,0 +1,357 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NormalizationOptions controls how reference and hypothesis texts are made
+// comparable before scoring them.
+type NormalizationOptions struct {
+	// KeepCase disables lowercasing.
+	KeepCase bool
+	// KeepPunctuation disables the removal of punctuation. Apostrophes inside
+	// words, as in "don't", are always kept.
+	KeepPunctuation bool
+	// Rules are applied in order after lowercasing and before removing
+	// punctuation.
+	Rules []NormalizationRule
+}
+
+// NormalizationRule replaces every match of Pattern with Replacement, which
+// may refer to submatches as in regexp.Regexp.ReplaceAllString.
+type NormalizationRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// LoadNormalizationRules reads one rule per line in the form
+// "pattern => replacement". Blank lines and lines starting with # are
+// skipped.
+func LoadNormalizationRules(file string) ([]NormalizationRule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening normalization rules: %w", err)
+	}
+	defer f.Close()
+
+	rules := make([]NormalizationRule, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		pattern, replacement, found := strings.Cut(text, "=>")
+		if !found {
+			return nil, fmt.Errorf("normalization rule on line %d has no =>", line)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern on line %d: %w", line, err)
+		}
+		rules = append(rules, NormalizationRule{Pattern: re, Replacement: strings.TrimSpace(replacement)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading normalization rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Normalize applies opts to text and collapses its whitespace.
+func Normalize(text string, opts NormalizationOptions) string {
+	if !opts.KeepCase {
+		text = strings.ToLower(text)
+	}
+	for _, rule := range opts.Rules {
+		text = rule.Pattern.ReplaceAllString(text, rule.Replacement)
+	}
+	if !opts.KeepPunctuation {
+		text = removePunctuation(text)
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func removePunctuation(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i, r := range runes {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if isApostrophe(r) && i > 0 && i < len(runes)-1 && unicode.IsLetter(runes[i-1]) && unicode.IsLetter(runes[i+1]) {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteRune(' ')
+	}
+	return b.String()
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// EditOperation is the kind of a step in the alignment of two sequences.
+type EditOperation string
+
+const (
+	EditMatch        EditOperation = "match"
+	EditSubstitution EditOperation = "substitution"
+	EditInsertion    EditOperation = "insertion"
+	EditDeletion     EditOperation = "deletion"
+)
+
+// AlignmentStep pairs a reference token with the hypothesis token it was
+// aligned to. Reference is empty for insertions and Hypothesis for
+// deletions.
+type AlignmentStep struct {
+	Operation  EditOperation `json:"operation"`
+	Reference  string        `json:"reference,omitempty"`
+	Hypothesis string        `json:"hypothesis,omitempty"`
+}
+
+// ErrorCounts is the outcome of aligning a hypothesis with its reference.
+type ErrorCounts struct {
+	Hits          int `json:"hits"`
+	Substitutions int `json:"substitutions"`
+	Insertions    int `json:"insertions"`
+	Deletions     int `json:"deletions"`
+	// ReferenceLength is the number of tokens of the reference.
+	ReferenceLength int `json:"reference_length"`
+}
+
+// Errors is the number of substitutions, insertions and deletions.
+func (c ErrorCounts) Errors() int {
+	return c.Substitutions + c.Insertions + c.Deletions
+}
+
+// Rate is the number of errors divided by the length of the reference. An
+// empty reference has a rate of 0 when the hypothesis is empty too, and 1
+// otherwise.
+func (c ErrorCounts) Rate() float64 {
+	if c.ReferenceLength == 0 {
+		if c.Errors() == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(c.Errors()) / float64(c.ReferenceLength)
+}
+
+func (c *ErrorCounts) add(other ErrorCounts) {
+	c.Hits += other.Hits
+	c.Substitutions += other.Substitutions
+	c.Insertions += other.Insertions
+	c.Deletions += other.Deletions
+	c.ReferenceLength += other.ReferenceLength
+}
+
+// Directions of the backtrace of align.
+const (
+	fromDiagonal byte = iota
+	fromAbove
+	fromLeft
+)
+
+// align finds a minimum edit distance alignment of hypothesis to reference.
+// Only two rows of costs are kept, plus one byte per cell to trace the
+// alignment back. Ties are broken in favour of the diagonal, then deletions,
+// so that the alignment of equal-length sequences stays diagonal.
+func align(reference, hypothesis []string) ([]AlignmentStep, ErrorCounts) {
+	columns := len(hypothesis) + 1
+	directions := make([]byte, (len(reference)+1)*columns)
+	previous := make([]int, columns)
+	current := make([]int, columns)
+	for j := range columns {
+		previous[j] = j
+		directions[j] = fromLeft
+	}
+	for i := 1; i <= len(reference); i++ {
+		current[0] = i
+		directions[i*columns] = fromAbove
+		for j := 1; j < columns; j++ {
+			cost, direction := previous[j-1], fromDiagonal
+			if reference[i-1] != hypothesis[j-1] {
+				cost++
+			}
+			if previous[j]+1 < cost {
+				cost, direction = previous[j]+1, fromAbove
+			}
+			if current[j-1]+1 < cost {
+				cost, direction = current[j-1]+1, fromLeft
+			}
+			current[j] = cost
+			directions[i*columns+j] = direction
+		}
+		previous, current = current, previous
+	}
+
+	counts := ErrorCounts{ReferenceLength: len(reference)}
+	steps := make([]AlignmentStep, 0, max(len(reference), len(hypothesis)))
+	i, j := len(reference), len(hypothesis)
+	for i > 0 || j > 0 {
+		switch directions[i*columns+j] {
+		case fromDiagonal:
+			if reference[i-1] == hypothesis[j-1] {
+				steps = append(steps, AlignmentStep{Operation: EditMatch, Reference: reference[i-1], Hypothesis: hypothesis[j-1]})
+				counts.Hits++
+			} else {
+				steps = append(steps, AlignmentStep{Operation: EditSubstitution, Reference: reference[i-1], Hypothesis: hypothesis[j-1]})
+				counts.Substitutions++
+			}
+			i, j = i-1, j-1
+		case fromAbove:
+			steps = append(steps, AlignmentStep{Operation: EditDeletion, Reference: reference[i-1]})
+			counts.Deletions++
+			i--
+		default:
+			steps = append(steps, AlignmentStep{Operation: EditInsertion, Hypothesis: hypothesis[j-1]})
+			counts.Insertions++
+			j--
+		}
+	}
+
+	for left, right := 0, len(steps)-1; left < right; left, right = left+1, right-1 {
+		steps[left], steps[right] = steps[right], steps[left]
+	}
+	return steps, counts
+}
+
+// countEdits works like align but only counts the edits, so it needs two
+// rows of memory however long the sequences are. It is used for characters,
+// whose alignment is never reported.
+func countEdits(reference, hypothesis []string) ErrorCounts {
+	columns := len(hypothesis) + 1
+	previous := make([]ErrorCounts, columns)
+	current := make([]ErrorCounts, columns)
+	for j := range columns {
+		previous[j] = ErrorCounts{Insertions: j}
+	}
+	for i := 1; i <= len(reference); i++ {
+		current[0] = ErrorCounts{Deletions: i}
+		for j := 1; j < columns; j++ {
+			best := previous[j-1]
+			if reference[i-1] == hypothesis[j-1] {
+				best.Hits++
+			} else {
+				best.Substitutions++
+			}
+			if previous[j].Errors()+1 < best.Errors() {
+				best = previous[j]
+				best.Deletions++
+			}
+			if current[j-1].Errors()+1 < best.Errors() {
+				best = current[j-1]
+				best.Insertions++
+			}
+			current[j] = best
+		}
+		previous, current = current, previous
+	}
+
+	counts := previous[columns-1]
+	counts.ReferenceLength = len(reference)
+	return counts
+}
+
+// Score is the accuracy of a single hypothesis.
+type Score struct {
+	// Reference and Hypothesis are the normalised texts that were compared.
+	Reference  string `json:"reference"`
+	Hypothesis string `json:"hypothesis"`
+	// WER is the word error rate of the hypothesis.
+	WER float64 `json:"wer"`
+	// CER is the character error rate of the hypothesis, spaces between
+	// words included.
+	CER        float64         `json:"cer"`
+	Words      ErrorCounts     `json:"words"`
+	Characters ErrorCounts     `json:"characters"`
+	Alignment  []AlignmentStep `json:"alignment"`
+}
+
+// ScoreTranscript normalises both texts with opts and aligns them word by
+// word and character by character.
+func ScoreTranscript(reference, hypothesis string, opts NormalizationOptions) Score {
+	reference = Normalize(reference, opts)
+	hypothesis = Normalize(hypothesis, opts)
+
+	alignment, words := align(strings.Fields(reference), strings.Fields(hypothesis))
+	characters := countEdits(strings.Split(reference, ""), strings.Split(hypothesis, ""))
+	return Score{
+		Reference:  reference,
+		Hypothesis: hypothesis,
+		WER:        words.Rate(),
+		CER:        characters.Rate(),
+		Words:      words,
+		Characters: characters,
+		Alignment:  alignment,
+	}
+}
+
+// Confusion is a word error together with the number of times it was made.
+// Reference is empty for insertions and Hypothesis for deletions.
+type Confusion struct {
+	Reference  string `json:"reference"`
+	Hypothesis string `json:"hypothesis"`
+	Count      int    `json:"count"`
+}
+
+// FileScore is the Score of one file of an evaluation.
+type FileScore struct {
+	Name string `json:"name"`
+	Score
+}
+
+// Evaluation aggregates the scores of a set of files.
+type Evaluation struct {
+	Files []FileScore `json:"files"`
+	// WER and CER are the error rates over all files, so that longer files
+	// weigh more.
+	WER        float64     `json:"wer"`
+	CER        float64     `json:"cer"`
+	Words      ErrorCounts `json:"words"`
+	Characters ErrorCounts `json:"characters"`
+	// Confusions holds every word error, the most frequent first.
+	Confusions []Confusion `json:"confusions"`
+}
+
+// NewEvaluation gathers the scores of a set of files into aggregate counts
+// and confusions.
+func NewEvaluation(files []FileScore) *Evaluation {
+	evaluation := &Evaluation{Files: files, Confusions: make([]Confusion, 0)}
+	counts := make(map[[2]string]int)
+	for _, file := range files {
+		evaluation.Words.add(file.Words)
+		evaluation.Characters.add(file.Characters)
+		for _, step := range file.Alignment {
+			if step.Operation != EditMatch {
+				counts[[2]string{step.Reference, step.Hypothesis}]++
+			}
+		}
+	}
+
+	evaluation.WER = evaluation.Words.Rate()
+	evaluation.CER = evaluation.Characters.Rate()
+
+	for pair, count := range counts {
+		evaluation.Confusions = append(evaluation.Confusions, Confusion{Reference: pair[0], Hypothesis: pair[1], Count: count})
+	}
+	sort.Slice(evaluation.Confusions, func(i, j int) bool {
+		a, b := evaluation.Confusions[i], evaluation.Confusions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Reference != b.Reference {
+			return a.Reference < b.Reference
+		}
+		return a.Hypothesis < b.Hypothesis
+	})
+	return evaluation
+}
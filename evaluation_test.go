@@ -0,0 +1,85 @@
+package verbio_speech_center
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "hello world don't stop", Normalize("  Hello, World!  Don't   stop... ", NormalizationOptions{}))
+	assert.Equal(t, "Hello, World!", Normalize("Hello,  World!", NormalizationOptions{KeepCase: true, KeepPunctuation: true}))
+	assert.Equal(t, "a b", Normalize("'a' - b", NormalizationOptions{}))
+
+	rules := []NormalizationRule{
+		{Pattern: regexp.MustCompile(`\bok\b`), Replacement: "okay"},
+		{Pattern: regexp.MustCompile(`(\d+)%`), Replacement: "$1 percent"},
+	}
+	assert.Equal(t, "okay 50 percent", Normalize("OK, 50%", NormalizationOptions{Rules: rules}))
+}
+
+func TestLoadNormalizationRules(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "rules.txt", []byte("# spelling\n\ngonna => going to\n(\\d)st => $1\n"))
+
+	rules, err := LoadNormalizationRules(file)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "going to win the 1 prize", Normalize("Gonna win the 1st prize", NormalizationOptions{Rules: rules}))
+
+	_, err = LoadNormalizationRules(writeTestFile(t, dir, "bad.txt", []byte("no arrow\n")))
+	assert.Error(t, err)
+	_, err = LoadNormalizationRules(writeTestFile(t, dir, "invalid.txt", []byte("( => x\n")))
+	assert.Error(t, err)
+	_, err = LoadNormalizationRules(filepath.Join(dir, "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestScoreTranscript(t *testing.T) {
+	score := ScoreTranscript("The cat sat on the mat.", "the cat sat at the mat now", NormalizationOptions{})
+	assert.Equal(t, ErrorCounts{Hits: 5, Substitutions: 1, Insertions: 1, ReferenceLength: 6}, score.Words)
+	assert.InDelta(t, 2.0/6, score.WER, 1e-9)
+	assert.Equal(t, []AlignmentStep{
+		{Operation: EditMatch, Reference: "the", Hypothesis: "the"},
+		{Operation: EditMatch, Reference: "cat", Hypothesis: "cat"},
+		{Operation: EditMatch, Reference: "sat", Hypothesis: "sat"},
+		{Operation: EditSubstitution, Reference: "on", Hypothesis: "at"},
+		{Operation: EditMatch, Reference: "the", Hypothesis: "the"},
+		{Operation: EditMatch, Reference: "mat", Hypothesis: "mat"},
+		{Operation: EditInsertion, Hypothesis: "now"},
+	}, score.Alignment)
+
+	// "the cat sat on the mat" is 22 characters; "on" -> "at" is two
+	// substitutions and " now" four insertions.
+	assert.Equal(t, 6, score.Characters.Errors())
+	assert.Equal(t, 22, score.Characters.ReferenceLength)
+	assert.InDelta(t, 6.0/22, score.CER, 1e-9)
+}
+
+func TestScoreTranscriptDeletions(t *testing.T) {
+	score := ScoreTranscript("one two three four", "one four", NormalizationOptions{})
+	assert.Equal(t, ErrorCounts{Hits: 2, Deletions: 2, ReferenceLength: 4}, score.Words)
+	assert.Equal(t, 0.5, score.WER)
+}
+
+func TestScoreTranscriptEmpty(t *testing.T) {
+	assert.Equal(t, 0.0, ScoreTranscript("", "", NormalizationOptions{}).WER)
+	assert.Equal(t, 1.0, ScoreTranscript("", "hello", NormalizationOptions{}).WER)
+	assert.Equal(t, 1.0, ScoreTranscript("hello", "", NormalizationOptions{}).WER)
+}
+
+func TestNewEvaluation(t *testing.T) {
+	evaluation := NewEvaluation([]FileScore{
+		{Name: "a.wav", Score: ScoreTranscript("yes please", "yes peas", NormalizationOptions{})},
+		{Name: "b.wav", Score: ScoreTranscript("please go on now", "peas go now", NormalizationOptions{})},
+	})
+
+	assert.Equal(t, ErrorCounts{Hits: 3, Substitutions: 2, Deletions: 1, ReferenceLength: 6}, evaluation.Words)
+	assert.Equal(t, 0.5, evaluation.WER)
+	assert.Equal(t, []Confusion{
+		{Reference: "please", Hypothesis: "peas", Count: 2},
+		{Reference: "on", Count: 1},
+	}, evaluation.Confusions)
+}
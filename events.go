@@ -0,0 +1,39 @@
+package verbio_speech_center
+
+import (
+	"time"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+)
+
+// RecognitionEvent is a hypothesis delivered while the audio is still being
+// recognised. Interim hypotheses may change until a final one is received.
+type RecognitionEvent struct {
+	IsFinal bool
+	// Text is the best transcript of the hypothesis.
+	Text string
+	// EndOfUtteranceSilence is the silence between the last recognised word and
+	// the end of the audio covered by the hypothesis.
+	EndOfUtteranceSilence time.Duration
+	// Segment holds the structured result of final hypotheses and is nil otherwise.
+	Segment *Segment
+}
+
+// ResultHandler receives recognition events in the order they arrive. It is
+// called from the goroutine receiving the responses, so it should return
+// quickly to avoid delaying the following results.
+type ResultHandler func(RecognitionEvent)
+
+// newRecognitionEvent builds the event of a result received after offset
+// seconds of final results.
+func newRecognitionEvent(result *sttv1.RecognitionResult, offset float32, silenceInMs int32) RecognitionEvent {
+	event := RecognitionEvent{
+		IsFinal:               result.IsFinal,
+		Text:                  result.Alternatives[0].Transcript,
+		EndOfUtteranceSilence: time.Duration(silenceInMs) * time.Millisecond,
+	}
+	if result.IsFinal {
+		segment := newSegment(result, offset)
+		event.Segment = &segment
+	}
+	return event
+}
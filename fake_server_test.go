@@ -0,0 +1,250 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+	ttsv1 "verbio_speech_center/proto/speechcenter/tts"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// recognizeStream is the server side of a recognition stream.
+type recognizeStream = grpc.BidiStreamingServer[sttv1.RecognitionStreamingRequest, sttv1.RecognitionStreamingResponse]
+
+// fakeRecognizerServer answers every stream with a single final result whose
+// transcript is the language of the configuration, so that concurrent
+// callers can check they got the answer to their own request.
+//
+// Its hooks are called with the number of the stream, counting from 1, and
+// fail it with the error they return.
+type fakeRecognizerServer struct {
+	sttv1.UnimplementedRecognizerServer
+	// started, when set, receives a value once a stream has read its configuration.
+	started chan struct{}
+	// hang keeps the stream open until the client goes away.
+	hang bool
+	// transcribe, when set, builds the result from the audio of the stream.
+	transcribe func(sampleRate uint32, audio []byte) *sttv1.RecognitionResult
+	// onConfig is called once the configuration has been read.
+	onConfig func(n int, stream recognizeStream) error
+	// onAudio is called with the audio received so far after every chunk.
+	onAudio func(n int, stream recognizeStream, sampleRate uint32, audio []byte) error
+	// onEnd is called once all the audio has been read, before the result
+	// is sent.
+	onEnd func(n int, stream recognizeStream) error
+
+	mu      sync.Mutex
+	streams int
+}
+
+func (f *fakeRecognizerServer) StreamingRecognize(stream recognizeStream) error {
+	f.mu.Lock()
+	f.streams++
+	n := f.streams
+	f.mu.Unlock()
+
+	request, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	language := request.GetConfig().GetParameters().GetLanguage()
+	sampleRate := request.GetConfig().GetParameters().GetPcm().GetSampleRateHz()
+	if f.started != nil {
+		f.started <- struct{}{}
+	}
+	if f.onConfig != nil {
+		if err := f.onConfig(n, stream); err != nil {
+			return err
+		}
+	}
+	if f.hang {
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}
+
+	var audio []byte
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if request.GetEventMessage() != nil && request.GetEventMessage().GetEvent() == sttv1.EventMessage_END_OF_STREAM {
+			break
+		}
+		audio = append(audio, request.GetAudio()...)
+		if f.onAudio != nil {
+			if err := f.onAudio(n, stream, sampleRate, audio); err != nil {
+				return err
+			}
+		}
+	}
+	if f.onEnd != nil {
+		if err := f.onEnd(n, stream); err != nil {
+			return err
+		}
+	}
+	if f.transcribe != nil {
+		return stream.Send(resultResponse(f.transcribe(sampleRate, audio)))
+	}
+	return stream.Send(resultResponse(finalResult(language, 1)))
+}
+
+// fakeTextToSpeechServer answers every stream with the bytes of the text it
+// was asked to synthesize.
+type fakeTextToSpeechServer struct {
+	ttsv1.UnimplementedTextToSpeechServer
+	// onRequest is called with the number of the stream, counting from 1,
+	// after every request it receives, and fails it with the error it
+	// returns.
+	onRequest func(n int) error
+
+	mu      sync.Mutex
+	streams int
+}
+
+func (f *fakeTextToSpeechServer) StreamingSynthesizeSpeech(stream grpc.BidiStreamingServer[ttsv1.StreamingSynthesisRequest, ttsv1.StreamingSynthesisResponse]) error {
+	f.mu.Lock()
+	f.streams++
+	n := f.streams
+	f.mu.Unlock()
+
+	var text string
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if f.onRequest != nil {
+			if err := f.onRequest(n); err != nil {
+				return err
+			}
+		}
+		text += request.GetText()
+	}
+	return stream.Send(&ttsv1.StreamingSynthesisResponse{
+		SynthesisResponse: &ttsv1.StreamingSynthesisResponse_StreamingAudio{
+			StreamingAudio: &ttsv1.StreamingAudio{AudioSamples: []byte(text)},
+		},
+	})
+}
+
+// startFakeServer serves the given services over an in-memory listener and
+// returns a connection to it. Everything is torn down when the test ends.
+func startFakeServer(t *testing.T, register func(*grpc.Server)) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	register(server)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("error connecting to fake server: %v", err)
+	}
+	return conn
+}
+
+// newFakeServerRecogniser connects a Recogniser with opts to server. Audio is
+// sent as fast as it is read unless opts set a pacing.
+func newFakeServerRecogniser(t *testing.T, server *fakeRecognizerServer, opts ...Option) *Recogniser {
+	conn := startFakeServer(t, func(s *grpc.Server) {
+		sttv1.RegisterRecognizerServer(s, server)
+	})
+	options := clientOptions{pacing: Pacing{ChunkDuration: DefaultPacing.ChunkDuration}, grammarType: GrammarAuto}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			t.Fatalf("invalid option: %v", err)
+		}
+	}
+	return &Recogniser{
+		conn:    conn,
+		client:  sttv1.NewRecognizerClient(conn),
+		options: options,
+	}
+}
+
+// newFakeServerSynthesizer connects a Synthesizer with opts to server.
+func newFakeServerSynthesizer(t *testing.T, server *fakeTextToSpeechServer, opts ...Option) *Synthesizer {
+	conn := startFakeServer(t, func(s *grpc.Server) {
+		ttsv1.RegisterTextToSpeechServer(s, server)
+	})
+	options, err := newClientOptions(opts)
+	if err != nil {
+		t.Fatalf("invalid option: %v", err)
+	}
+	return &Synthesizer{
+		conn:    conn,
+		client:  ttsv1.NewTextToSpeechClient(conn),
+		options: options,
+	}
+}
+
+func TestFakeServerRecognition(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{})
+	defer recogniser.Close()
+
+	audio := make([]byte, 16000)
+	res, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(audio), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic", Language: "en-US"}, SampleRate: 8000})
+	assert.NoError(t, err)
+	assert.Equal(t, "en-US", res)
+}
+
+func TestRecogniserClose(t *testing.T) {
+	server := &fakeRecognizerServer{started: make(chan struct{}, 1), hang: true}
+	recogniser := newFakeServerRecogniser(t, server)
+
+	errs := make(chan error)
+	go func() {
+		_, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 1600)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic", Language: "en-US"}, SampleRate: 8000})
+		errs <- err
+	}()
+	<-server.started
+
+	err := recogniser.Close()
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	err = <-errs
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	assert.Error(t, recogniser.Close())
+
+	_, err = recogniser.RecogniseStream(context.Background(), bytes.NewReader(nil), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 8000})
+	assert.True(t, errors.Is(err, ErrClientClosed), "unexpected error: %v", err)
+}
+
+func TestRecogniserShutdownWaitsForSessions(t *testing.T) {
+	server := &fakeRecognizerServer{started: make(chan struct{}, 1)}
+	recogniser := newFakeServerRecogniser(t, server)
+	recogniser.options.pacing = DefaultPacing
+
+	results := make(chan string)
+	go func() {
+		res, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 3200)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic", Language: "en-US"}, SampleRate: 8000})
+		assert.NoError(t, err)
+		results <- res
+	}()
+	<-server.started
+
+	assert.NoError(t, recogniser.Shutdown(context.Background()))
+	assert.Equal(t, "en-US", <-results)
+}
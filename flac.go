@@ -0,0 +1,236 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"verbio_speech_center/log"
+
+	"github.com/mewkiz/flac"
+)
+
+var (
+	flacSignature = []byte("fLaC")
+	oggSignature  = []byte("OggS")
+	// oggFlacSignature starts the first packet of an Ogg FLAC stream.
+	oggFlacSignature = []byte("\x7fFLAC")
+)
+
+// readAudio reads a WAV, FLAC or Ogg FLAC file, told apart by their
+// signature.
+func readAudio(r io.ReadSeeker) (*wavContents, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("error reading audio file: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading audio file: %w", err)
+	}
+
+	switch {
+	case bytes.Equal(magic, flacSignature):
+		return readFlac(bufio.NewReader(r))
+	case bytes.Equal(magic, oggSignature):
+		native, err := demuxOggFlac(bufio.NewReader(r))
+		if err != nil {
+			return nil, err
+		}
+		return readFlac(bytes.NewReader(native))
+	default:
+		return readWav(r)
+	}
+}
+
+// readFlac decodes a native FLAC stream into 16-bit LPCM.
+func readFlac(r io.Reader) (*wavContents, error) {
+	reader, err := newFlacReader(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	info := reader.stream.Info
+	log.Logger.Debugf("Decoded FLAC audio [bitDepth=%d] [sampleRate=%d] [channels=%d] [bytes=%d]",
+		info.BitsPerSample, info.SampleRate, info.NChannels, len(data))
+	return &wavContents{
+		data:       data,
+		formatTag:  wavFormatPCM,
+		bitDepth:   pcmBitDepth,
+		sampleRate: reader.sampleRate(),
+		channels:   reader.channels(),
+	}, nil
+}
+
+// flacReader decodes a native FLAC stream frame by frame into interleaved
+// 16-bit little-endian LPCM, scaling samples of any other bit depth.
+type flacReader struct {
+	stream  *flac.Stream
+	pending []byte
+}
+
+func newFlacReader(r io.Reader) (*flacReader, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid FLAC file: %w", err)
+	}
+	if stream.Info.SampleRate == 0 || stream.Info.NChannels == 0 {
+		return nil, errors.New("invalid FLAC stream info")
+	}
+	return &flacReader{stream: stream}, nil
+}
+
+func (f *flacReader) sampleRate() uint32 {
+	return f.stream.Info.SampleRate
+}
+
+func (f *flacReader) channels() uint16 {
+	return uint16(f.stream.Info.NChannels)
+}
+
+func (f *flacReader) Read(p []byte) (int, error) {
+	for len(f.pending) == 0 {
+		if err := f.decodeFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, f.pending)
+	f.pending = f.pending[n:]
+	return n, nil
+}
+
+// decodeFrame decodes the next frame into pending. It returns io.EOF at the
+// end of the stream.
+func (f *flacReader) decodeFrame() error {
+	frame, err := f.stream.ParseNext()
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding FLAC frame: %w", err)
+	}
+	if len(frame.Subframes) != int(f.stream.Info.NChannels) {
+		return fmt.Errorf("FLAC frame has %d channels, stream has %d", len(frame.Subframes), f.stream.Info.NChannels)
+	}
+
+	bitDepth := frame.BitsPerSample
+	if bitDepth == 0 {
+		bitDepth = f.stream.Info.BitsPerSample
+	}
+	samples := frame.Subframes[0].NSamples
+	data := make([]byte, 0, samples*len(frame.Subframes)*bytesPerSample)
+	for i := 0; i < samples; i++ {
+		for _, subframe := range frame.Subframes {
+			data = binary.LittleEndian.AppendUint16(data, uint16(scaleToLPCM16(subframe.Samples[i], bitDepth)))
+		}
+	}
+	f.pending = data
+	return nil
+}
+
+// scaleToLPCM16 rescales a sample of the given bit depth to 16 bits.
+func scaleToLPCM16(sample int32, bitDepth uint8) int16 {
+	if bitDepth > pcmBitDepth {
+		return int16(sample >> (bitDepth - pcmBitDepth))
+	}
+	return int16(sample << (pcmBitDepth - bitDepth))
+}
+
+// demuxOggFlac extracts the FLAC stream of an Ogg FLAC file as a native FLAC
+// stream: the STREAMINFO block carried by the first packet followed by the
+// audio frames, one per packet. Other metadata blocks are dropped.
+func demuxOggFlac(r io.Reader) ([]byte, error) {
+	packets, err := readOggPackets(r)
+	if err != nil {
+		return nil, err
+	}
+	// The first packet holds the mapping header, the number of header packets
+	// and the native signature, followed by the STREAMINFO block.
+	if len(packets) == 0 || len(packets[0]) < 13 || !bytes.HasPrefix(packets[0], oggFlacSignature) ||
+		!bytes.Equal(packets[0][9:13], flacSignature) {
+		return nil, errors.New("not an Ogg FLAC file")
+	}
+
+	native := append([]byte(nil), flacSignature...)
+	streamInfo := append([]byte(nil), packets[0][13:]...)
+	// Mark STREAMINFO as the last metadata block.
+	streamInfo[0] |= 0x80
+	native = append(native, streamInfo...)
+	for _, packet := range packets[1:] {
+		if isFlacFrame(packet) {
+			native = append(native, packet...)
+		}
+	}
+	return native, nil
+}
+
+// isFlacFrame tells audio frames, which start with a sync code, apart from
+// metadata blocks.
+func isFlacFrame(packet []byte) bool {
+	return len(packet) >= 2 && packet[0] == 0xFF && packet[1]&0xFC == 0xF8
+}
+
+// readOggPackets returns the packets of the first logical bitstream of an Ogg
+// file, reassembled from their page segments.
+func readOggPackets(r io.Reader) ([][]byte, error) {
+	var packets [][]byte
+	var packet []byte
+	var serial uint32
+	for page := 0; ; page++ {
+		var header struct {
+			Signature  [4]byte
+			Version    uint8
+			HeaderType uint8
+			Granule    uint64
+			Serial     uint32
+			Sequence   uint32
+			Checksum   uint32
+			Segments   uint8
+		}
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			if err == io.EOF && page > 0 {
+				break
+			}
+			return nil, fmt.Errorf("error reading Ogg page header: %w", err)
+		}
+		if !bytes.Equal(header.Signature[:], oggSignature) {
+			return nil, errors.New("invalid Ogg page signature")
+		}
+		if page == 0 {
+			serial = header.Serial
+		}
+
+		segments := make([]byte, header.Segments)
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return nil, fmt.Errorf("error reading Ogg segment table: %w", err)
+		}
+		var size int
+		for _, segment := range segments {
+			size += int(segment)
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, fmt.Errorf("error reading Ogg page: %w", err)
+		}
+		if header.Serial != serial {
+			continue
+		}
+
+		// A packet ends with the first segment shorter than 255 bytes, and
+		// may continue on the next page.
+		for _, segment := range segments {
+			packet = append(packet, body[:segment]...)
+			body = body[segment:]
+			if segment < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+	return packets, nil
+}
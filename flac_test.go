@@ -0,0 +1,214 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeFlac encodes the samples of every channel as a native FLAC stream of
+// verbatim frames, returning the stream and the offset at which each frame
+// starts.
+func encodeFlac(t *testing.T, sampleRate uint32, bitDepth uint8, blockSize int, channels ...[]int32) ([]byte, []int) {
+	var out bytes.Buffer
+	info := &meta.StreamInfo{
+		BlockSizeMin:  uint16(blockSize),
+		BlockSizeMax:  uint16(blockSize),
+		SampleRate:    sampleRate,
+		NChannels:     uint8(len(channels)),
+		BitsPerSample: bitDepth,
+		NSamples:      uint64(len(channels[0])),
+	}
+	enc, err := flac.NewEncoder(&out, info)
+	assert.NoError(t, err)
+	enc.EnablePredictionAnalysis(false)
+
+	layout := frame.ChannelsMono
+	if len(channels) == 2 {
+		layout = frame.ChannelsLR
+	}
+	var offsets []int
+	for start := 0; start < len(channels[0]); start += blockSize {
+		end := start + blockSize
+		if end > len(channels[0]) {
+			end = len(channels[0])
+		}
+		f := &frame.Frame{Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(end - start),
+			SampleRate:        sampleRate,
+			Channels:          layout,
+			BitsPerSample:     bitDepth,
+		}}
+		for _, samples := range channels {
+			f.Subframes = append(f.Subframes, &frame.Subframe{
+				SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+				Samples:   samples[start:end],
+				NSamples:  end - start,
+			})
+		}
+		offsets = append(offsets, out.Len())
+		assert.NoError(t, enc.WriteFrame(f))
+	}
+	assert.NoError(t, enc.Close())
+	return out.Bytes(), offsets
+}
+
+// oggPage builds an Ogg page holding the given lacing values and body.
+func oggPage(serial uint32, sequence uint32, lacing []byte, body []byte) []byte {
+	var page bytes.Buffer
+	page.Write(oggSignature)
+	page.Write([]byte{0, 0})
+	_ = binary.Write(&page, binary.LittleEndian, uint64(0))
+	_ = binary.Write(&page, binary.LittleEndian, serial)
+	_ = binary.Write(&page, binary.LittleEndian, sequence)
+	_ = binary.Write(&page, binary.LittleEndian, uint32(0))
+	page.WriteByte(byte(len(lacing)))
+	page.Write(lacing)
+	page.Write(body)
+	return page.Bytes()
+}
+
+// lacing returns the segment sizes of a packet.
+func lacing(packet []byte) []byte {
+	var segments []byte
+	for size := len(packet); ; size -= 255 {
+		if size < 255 {
+			return append(segments, byte(size))
+		}
+		segments = append(segments, 255)
+	}
+}
+
+// wrapOggFlac wraps a native FLAC stream in Ogg pages, one packet per page,
+// splitting the last frame across two pages.
+func wrapOggFlac(native []byte, offsets []int) []byte {
+	// The STREAMINFO block follows the signature and its block header.
+	header := append([]byte("\x7fFLAC\x01\x00\x00\x01fLaC"), native[4:42]...)
+	comment := []byte{0x84, 0, 0, 0}
+	packets := [][]byte{header, comment}
+	for i, offset := range offsets {
+		end := len(native)
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		packets = append(packets, native[offset:end])
+	}
+
+	var ogg []byte
+	var sequence uint32
+	for i, packet := range packets {
+		segments := lacing(packet)
+		if i == len(packets)-1 && len(segments) > 1 {
+			ogg = append(ogg, oggPage(1, sequence, segments[:1], packet[:255])...)
+			sequence++
+			ogg = append(ogg, oggPage(1, sequence, segments[1:], packet[255:])...)
+		} else {
+			ogg = append(ogg, oggPage(1, sequence, segments, packet)...)
+		}
+		sequence++
+		if i == 0 {
+			// Pages of another logical stream are skipped.
+			ogg = append(ogg, oggPage(7, 0, []byte{3}, []byte{1, 2, 3})...)
+		}
+	}
+	return ogg
+}
+
+func ramp(count int, step int32) []int32 {
+	samples := make([]int32, count)
+	for i := range samples {
+		samples[i] = int32(i-count/2) * step
+	}
+	return samples
+}
+
+func TestLoadAudioFlac(t *testing.T) {
+	samples := ramp(300, 100)
+	native, _ := encodeFlac(t, 16000, 16, 128, samples)
+
+	audio, err := loadAudio(writeTestFile(t, t.TempDir(), "audio.flac", native), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(16000), audio.sampleRate)
+	assert.Equal(t, uint16(1), audio.channels)
+	decoded := lpcmSamples(audio.samples)
+	assert.Len(t, decoded, 300)
+	for i, sample := range samples {
+		assert.Equal(t, int16(sample), decoded[i])
+	}
+
+	converted, err := loadAudio(writeTestFile(t, t.TempDir(), "audio.flac", native), 8000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8000), converted.sampleRate)
+	assert.Len(t, converted.samples, 150*bytesPerSample)
+}
+
+func TestLoadAudioFlacBitDepths(t *testing.T) {
+	native, _ := encodeFlac(t, 8000, 24, 16, []int32{0x7FFFFF, -0x800000, 0x100})
+	audio, err := loadAudio(writeTestFile(t, t.TempDir(), "audio.flac", native), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{32767, -32768, 1}, lpcmSamples(audio.samples))
+
+	native, _ = encodeFlac(t, 8000, 8, 16, []int32{127, -128, 1})
+	audio, err = loadAudio(writeTestFile(t, t.TempDir(), "audio.flac", native), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{32512, -32768, 256}, lpcmSamples(audio.samples))
+}
+
+func TestLoadChannelsFlac(t *testing.T) {
+	native, _ := encodeFlac(t, 8000, 16, 16, []int32{1, 2, 3}, []int32{-1, -2, -3})
+
+	channels, err := loadChannels(writeTestFile(t, t.TempDir(), "audio.flac", native), 0)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	assert.Equal(t, []int16{1, 2, 3}, lpcmSamples(channels[0].samples))
+	assert.Equal(t, []int16{-1, -2, -3}, lpcmSamples(channels[1].samples))
+}
+
+func TestLoadAudioOggFlac(t *testing.T) {
+	samples := ramp(500, 50)
+	native, offsets := encodeFlac(t, 8000, 16, 192, samples)
+
+	audio, err := loadAudio(writeTestFile(t, t.TempDir(), "audio.oga", wrapOggFlac(native, offsets)), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8000), audio.sampleRate)
+	decoded := lpcmSamples(audio.samples)
+	assert.Len(t, decoded, 500)
+	for i, sample := range samples {
+		assert.Equal(t, int16(sample), decoded[i])
+	}
+}
+
+func TestLoadAudioInvalidOgg(t *testing.T) {
+	_, err := loadAudio(writeTestFile(t, t.TempDir(), "audio.ogg", oggPage(1, 0, []byte{7}, []byte("\x01vorbis"))), 0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not an Ogg FLAC file")
+}
+
+func TestOpenAudioStreamFlac(t *testing.T) {
+	samples := ramp(100, 10)
+	native, offsets := encodeFlac(t, 16000, 16, 32, samples, samples)
+
+	for name, contents := range map[string][]byte{"native": native, "ogg": wrapOggFlac(native, offsets)} {
+		t.Run(name, func(t *testing.T) {
+			reader, sampleRate, channels, err := openAudioStream(bufio.NewReader(bytes.NewReader(contents)), StreamConfig{})
+			assert.NoError(t, err)
+			assert.Equal(t, uint32(16000), sampleRate)
+			assert.Equal(t, uint16(2), channels)
+			data, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Len(t, data, 100*2*bytesPerSample)
+		})
+	}
+
+	_, _, _, err := openAudioStream(bufio.NewReader(bytes.NewReader(native)), StreamConfig{Encoding: EncodingMuLaw})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "FLAC audio cannot be decoded as mulaw")
+}
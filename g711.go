@@ -0,0 +1,146 @@
+package verbio_speech_center
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	wavFormatALaw  = 6
+	wavFormatMuLaw = 7
+	g711BitDepth   = 8
+)
+
+// AudioEncoding is the encoding of headerless audio.
+type AudioEncoding string
+
+const (
+	// EncodingPCM is 16-bit little-endian LPCM, sent as is.
+	EncodingPCM AudioEncoding = "pcm"
+	// EncodingMuLaw is 8-bit G.711 mu-law, decoded to LPCM before sending.
+	EncodingMuLaw AudioEncoding = "mulaw"
+	// EncodingALaw is 8-bit G.711 A-law, decoded to LPCM before sending.
+	EncodingALaw AudioEncoding = "alaw"
+)
+
+// ParseAudioEncoding returns the AudioEncoding named by name.
+func ParseAudioEncoding(name string) (AudioEncoding, error) {
+	switch encoding := AudioEncoding(name); encoding {
+	case EncodingPCM, EncodingMuLaw, EncodingALaw:
+		return encoding, nil
+	default:
+		return "", fmt.Errorf("invalid audio encoding: %s (must be pcm, mulaw or alaw)", name)
+	}
+}
+
+// g711Table is the 16-bit LPCM value of every G.711 code.
+type g711Table [256]int16
+
+var (
+	muLawTable = newMuLawTable()
+	aLawTable  = newALawTable()
+)
+
+// table returns the decoding table of a G.711 encoding, or nil for LPCM.
+func (e AudioEncoding) table() *g711Table {
+	switch e {
+	case EncodingMuLaw:
+		return &muLawTable
+	case EncodingALaw:
+		return &aLawTable
+	default:
+		return nil
+	}
+}
+
+// wavEncoding returns the encoding of a WAV format tag, if it is supported.
+func wavEncoding(formatTag uint16) (AudioEncoding, bool) {
+	switch formatTag {
+	case wavFormatPCM:
+		return EncodingPCM, true
+	case wavFormatMuLaw:
+		return EncodingMuLaw, true
+	case wavFormatALaw:
+		return EncodingALaw, true
+	default:
+		return "", false
+	}
+}
+
+// newMuLawTable expands every mu-law code as described in ITU-T G.711: the
+// code is stored inverted, with a sign bit, a 3-bit segment and a 4-bit step
+// within the segment, on a scale biased by 0x84.
+func newMuLawTable() g711Table {
+	var table g711Table
+	for code := range table {
+		inverted := ^byte(code)
+		magnitude := ((int(inverted&0x0F) << 3) + 0x84) << ((inverted & 0x70) >> 4)
+		if inverted&0x80 != 0 {
+			table[code] = int16(0x84 - magnitude)
+		} else {
+			table[code] = int16(magnitude - 0x84)
+		}
+	}
+	return table
+}
+
+// newALawTable expands every A-law code as described in ITU-T G.711: even
+// bits are inverted, and the code holds a sign bit, a 3-bit segment and a
+// 4-bit step within the segment.
+func newALawTable() g711Table {
+	var table g711Table
+	for code := range table {
+		value := byte(code) ^ 0x55
+		magnitude := int(value&0x0F) << 4
+		segment := (value & 0x70) >> 4
+		switch segment {
+		case 0:
+			magnitude += 8
+		default:
+			magnitude = (magnitude + 0x108) << (segment - 1)
+		}
+		if value&0x80 != 0 {
+			table[code] = int16(magnitude)
+		} else {
+			table[code] = int16(-magnitude)
+		}
+	}
+	return table
+}
+
+// decode expands G.711 codes into 16-bit little-endian LPCM.
+func (t *g711Table) decode(codes []byte) []byte {
+	out := make([]byte, len(codes)*bytesPerSample)
+	for i, code := range codes {
+		binary.LittleEndian.PutUint16(out[i*bytesPerSample:], uint16(t[code]))
+	}
+	return out
+}
+
+// g711Reader decodes the G.711 codes read from an underlying reader into
+// 16-bit little-endian LPCM as they are read.
+type g711Reader struct {
+	reader io.Reader
+	table  *g711Table
+	codes  []byte
+}
+
+func newG711Reader(reader io.Reader, table *g711Table) *g711Reader {
+	return &g711Reader{reader: reader, table: table}
+}
+
+func (g *g711Reader) Read(p []byte) (int, error) {
+	count := len(p) / bytesPerSample
+	if count == 0 {
+		return 0, io.ErrShortBuffer
+	}
+	if len(g.codes) < count {
+		g.codes = make([]byte, count)
+	}
+	n, err := g.reader.Read(g.codes[:count])
+	for i, code := range g.codes[:n] {
+		binary.LittleEndian.PutUint16(p[i*bytesPerSample:], uint16(g.table[code]))
+	}
+	return n * bytesPerSample, err
+}
@@ -0,0 +1,174 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Reference values of ITU-T G.711, as decoded by the Sun reference
+// implementation.
+var muLawVectors = map[byte]int16{
+	0x00: -32124,
+	0x0F: -16764,
+	0x70: -120,
+	0x7F: 0,
+	0x80: 32124,
+	0xFE: 8,
+	0xFF: 0,
+}
+
+var aLawVectors = map[byte]int16{
+	0x00: -5504,
+	0x2A: -32256,
+	0x55: -8,
+	0x80: 5504,
+	0xAA: 32256,
+	0xC5: 264,
+	0xD4: 24,
+	0xD5: 8,
+}
+
+func TestMuLawTable(t *testing.T) {
+	for code, expected := range muLawVectors {
+		assert.Equal(t, expected, muLawTable[code], "mu-law code %#02x", code)
+	}
+	for code := 0; code < 0x80; code++ {
+		// The two halves of the table mirror each other around zero.
+		assert.Equal(t, -muLawTable[code], muLawTable[code|0x80], "mu-law code %#02x", code)
+	}
+	for code := 0x80; code < 0xFF; code++ {
+		assert.True(t, muLawTable[code] > muLawTable[code+1], "mu-law codes %#02x and %#02x", code, code+1)
+	}
+}
+
+func TestALawTable(t *testing.T) {
+	for code, expected := range aLawVectors {
+		assert.Equal(t, expected, aLawTable[code], "A-law code %#02x", code)
+	}
+	for code := 0; code < 0x80; code++ {
+		assert.Equal(t, -aLawTable[code], aLawTable[code|0x80], "A-law code %#02x", code)
+	}
+}
+
+func TestParseAudioEncoding(t *testing.T) {
+	encoding, err := ParseAudioEncoding("alaw")
+	assert.NoError(t, err)
+	assert.Equal(t, EncodingALaw, encoding)
+
+	_, err = ParseAudioEncoding("gsm")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be pcm, mulaw or alaw")
+}
+
+func TestG711Reader(t *testing.T) {
+	codes := []byte{0x00, 0x80, 0xFF, 0xFE, 0x0F}
+	// A small buffer forces several reads of a single byte.
+	reader := newG711Reader(bytes.NewReader(codes), &muLawTable)
+	var decoded []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := reader.Read(buf)
+		decoded = append(decoded, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, muLawTable.decode(codes), decoded)
+	assert.Equal(t, []int16{-32124, 32124, 0, 8, -16764}, lpcmSamples(decoded))
+
+	_, err := reader.Read(make([]byte, 1))
+	assert.Equal(t, io.ErrShortBuffer, err)
+}
+
+func TestLoadAudioG711(t *testing.T) {
+	file := createTemporaryWavFormat(t, wavFormatALaw, 8000, 8, 1, []int{0xD5, 0x55, 0xAA})
+
+	audio, err := loadAudio(file, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8000), audio.sampleRate)
+	assert.Equal(t, []int16{8, -8, 32256}, lpcmSamples(audio.samples))
+
+	converted, err := loadAudio(createTemporaryWavFormat(t, wavFormatMuLaw, 8000, 8, 2, []int{0x80, 0x00, 0x80, 0x00}), 8000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(1), converted.channels)
+	assert.Len(t, converted.samples, 2*bytesPerSample)
+}
+
+func TestLoadChannelsG711(t *testing.T) {
+	file := createTemporaryWavFormat(t, wavFormatMuLaw, 8000, 8, 2, []int{0x80, 0x00, 0xFE, 0xFF})
+
+	channels, err := loadChannels(file, 0)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	assert.Equal(t, []int16{32124, 8}, lpcmSamples(channels[0].samples))
+	assert.Equal(t, []int16{-32124, 0}, lpcmSamples(channels[1].samples))
+}
+
+func TestOpenAudioStreamG711Wav(t *testing.T) {
+	audio, err := os.ReadFile(createTemporaryWavFormat(t, wavFormatMuLaw, 8000, 8, 1, []int{0x00, 0x80}))
+	assert.NoError(t, err)
+
+	samples, sampleRate, channels, err := openAudioStream(bufio.NewReader(bytes.NewReader(audio)), StreamConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8000), sampleRate)
+	assert.Equal(t, uint16(1), channels)
+	data, err := io.ReadAll(samples)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{-32124, 32124}, lpcmSamples(data))
+
+	_, _, _, err = openAudioStream(bufio.NewReader(bytes.NewReader(audio)), StreamConfig{Encoding: EncodingALaw})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "WAV header declares mulaw audio but alaw was requested")
+
+	audio, err = os.ReadFile(createTemporaryWavFormat(t, wavFormatALaw, 8000, 16, 1, []int{0, 0}))
+	assert.NoError(t, err)
+	_, _, _, err = openAudioStream(bufio.NewReader(bytes.NewReader(audio)), StreamConfig{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "alaw audio must be 8-bit")
+}
+
+func TestOpenAudioStreamRawG711(t *testing.T) {
+	samples, sampleRate, _, err := openAudioStream(bufio.NewReader(bytes.NewReader([]byte{0xD5, 0x2A})), StreamConfig{SampleRate: 8000, Encoding: EncodingALaw})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8000), sampleRate)
+	data, err := io.ReadAll(samples)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{8, -32256}, lpcmSamples(data))
+
+	_, _, _, err = openAudioStream(bufio.NewReader(bytes.NewReader([]byte{0xD5})), StreamConfig{SampleRate: 8000, Encoding: "gsm"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid audio encoding")
+}
+
+func TestRecogniseStreamMuLaw(t *testing.T) {
+	stream := newFakeRecognitionStream(resultResponse(finalResult("hello", 1)))
+	recogniser := newFakeRecogniser(stream, DefaultPacing)
+
+	config := StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "telco"}, SampleRate: 8000, Encoding: EncodingMuLaw}
+	res, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(bytes.Repeat([]byte{0xFF}, 400)), config)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", res)
+
+	assert.Equal(t, uint32(8000), stream.requests[0].GetConfig().GetParameters().GetPcm().GetSampleRateHz())
+	var sent int
+	for _, request := range stream.requests[1:] {
+		sent += len(request.GetAudio())
+	}
+	assert.Equal(t, 400*bytesPerSample, sent)
+}
+
+func lpcmSamples(data []byte) []int16 {
+	samples := make([]int16, len(data)/bytesPerSample)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[i*bytesPerSample:]))
+	}
+	return samples
+}
@@ -0,0 +1,122 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"unicode/utf8"
+	"verbio_speech_center/log"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+)
+
+// GrammarType tells how the grammar given to a recognition is sent to the
+// service.
+type GrammarType string
+
+const (
+	// GrammarAuto sends a grammar URI as such when no file exists at that
+	// path, and otherwise picks GrammarInline or GrammarCompiled from the
+	// contents of the file.
+	GrammarAuto GrammarType = "auto"
+	// GrammarCompiled sends the file as a pre-compiled grammar blob.
+	GrammarCompiled GrammarType = "compiled"
+	// GrammarInline sends the file as ABNF or GRXML source text.
+	GrammarInline GrammarType = "inline"
+	// GrammarURI sends the grammar path as a URI, e.g. of a built-in grammar,
+	// without reading any file.
+	GrammarURI GrammarType = "uri"
+)
+
+// grammarURIPattern matches a URI scheme. Schemes of a single letter are left
+// out so that Windows drive letters are taken for paths.
+var grammarURIPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]+:`)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseGrammarType returns the GrammarType named by name.
+func ParseGrammarType(name string) (GrammarType, error) {
+	switch grammarType := GrammarType(name); grammarType {
+	case GrammarAuto, GrammarCompiled, GrammarInline, GrammarURI:
+		return grammarType, nil
+	default:
+		return "", fmt.Errorf("invalid grammar type: %s (must be auto, compiled, inline or uri)", name)
+	}
+}
+
+// WithGrammarType sets how grammars are sent to the service. Without it,
+// GrammarAuto is used.
+func WithGrammarType(grammarType GrammarType) Option {
+	return func(o *clientOptions) error {
+		if _, err := ParseGrammarType(string(grammarType)); err != nil {
+			return err
+		}
+		o.grammarType = grammarType
+		return nil
+	}
+}
+
+// loadGrammar builds the grammar resource for grammar, which is a file path
+// or, for GrammarURI and GrammarAuto, a URI.
+func loadGrammar(grammar string, grammarType GrammarType) (*sttv1.GrammarResource, error) {
+	if grammarType == GrammarURI {
+		return grammarURIResource(grammar), nil
+	}
+
+	contents, err := os.ReadFile(grammar)
+	if errors.Is(err, os.ErrNotExist) && grammarType == GrammarAuto && grammarURIPattern.MatchString(grammar) {
+		return grammarURIResource(grammar), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading grammar file: %w", err)
+	}
+
+	switch grammarType {
+	case GrammarCompiled:
+		return compiledGrammarResource(contents), nil
+	case GrammarInline:
+		if !utf8.Valid(contents) {
+			return nil, errors.New("inline grammar is not valid UTF-8 text")
+		}
+		return inlineGrammarResource(contents), nil
+	default:
+		if isGrammarSource(contents) {
+			return inlineGrammarResource(contents), nil
+		}
+		return compiledGrammarResource(contents), nil
+	}
+}
+
+// isGrammarSource tells whether contents look like an ABNF grammar, which
+// starts with an "#ABNF" header, or a GRXML one.
+func isGrammarSource(contents []byte) bool {
+	text := bytes.TrimSpace(bytes.TrimPrefix(contents, utf8BOM))
+	if !utf8.Valid(text) {
+		return false
+	}
+	return bytes.HasPrefix(text, []byte("#ABNF")) ||
+		bytes.HasPrefix(text, []byte("<?xml")) ||
+		bytes.HasPrefix(text, []byte("<grammar"))
+}
+
+func grammarURIResource(uri string) *sttv1.GrammarResource {
+	log.Logger.Debugf("Using grammar URI: %s", uri)
+	return &sttv1.GrammarResource{
+		Grammar: &sttv1.GrammarResource_GrammarUri{GrammarUri: uri},
+	}
+}
+
+func inlineGrammarResource(contents []byte) *sttv1.GrammarResource {
+	log.Logger.Debugf("Using inline grammar (%d bytes)", len(contents))
+	return &sttv1.GrammarResource{
+		Grammar: &sttv1.GrammarResource_InlineGrammar{InlineGrammar: string(bytes.TrimPrefix(contents, utf8BOM))},
+	}
+}
+
+func compiledGrammarResource(contents []byte) *sttv1.GrammarResource {
+	log.Logger.Debugf("Using compiled grammar (%d bytes)", len(contents))
+	return &sttv1.GrammarResource{
+		Grammar: &sttv1.GrammarResource_CompiledGrammar{CompiledGrammar: contents},
+	}
+}
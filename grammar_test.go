@@ -0,0 +1,82 @@
+package verbio_speech_center
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const abnfGrammar = "#ABNF 1.0 UTF-8;\nlanguage en-US;\nroot $answer;\n$answer = yes | no;\n"
+
+const grxmlGrammar = `<?xml version="1.0" encoding="UTF-8"?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" root="answer"/>
+`
+
+func TestLoadGrammarAuto(t *testing.T) {
+	dir := t.TempDir()
+
+	grammar, err := loadGrammar(writeTestFile(t, dir, "answer.abnf", []byte("\xEF\xBB\xBF\n"+abnfGrammar)), GrammarAuto)
+	assert.NoError(t, err)
+	assert.Equal(t, "\n"+abnfGrammar, grammar.GetInlineGrammar())
+
+	grammar, err = loadGrammar(writeTestFile(t, dir, "answer.grxml", []byte(grxmlGrammar)), GrammarAuto)
+	assert.NoError(t, err)
+	assert.Equal(t, grxmlGrammar, grammar.GetInlineGrammar())
+
+	compiled := "\x00\x01\x02compiled"
+	grammar, err = loadGrammar(writeTestFile(t, dir, "answer.bin", []byte(compiled)), GrammarAuto)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(compiled), grammar.GetCompiledGrammar())
+
+	grammar, err = loadGrammar("builtin:speech/boolean", GrammarAuto)
+	assert.NoError(t, err)
+	assert.Equal(t, "builtin:speech/boolean", grammar.GetGrammarUri())
+
+	_, err = loadGrammar(filepath.Join(dir, "missing.abnf"), GrammarAuto)
+	assert.Error(t, err)
+}
+
+func TestLoadGrammarExplicitType(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "answer.abnf", []byte(abnfGrammar))
+
+	grammar, err := loadGrammar(file, GrammarCompiled)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(abnfGrammar), grammar.GetCompiledGrammar())
+
+	grammar, err = loadGrammar(file, GrammarURI)
+	assert.NoError(t, err)
+	assert.Equal(t, file, grammar.GetGrammarUri())
+
+	grammar, err = loadGrammar(writeTestFile(t, dir, "custom.txt", []byte("$answer = yes | no;")), GrammarInline)
+	assert.NoError(t, err)
+	assert.Equal(t, "$answer = yes | no;", grammar.GetInlineGrammar())
+
+	_, err = loadGrammar(writeTestFile(t, dir, "binary.bin", []byte("\xff\xfe")), GrammarInline)
+	assert.Error(t, err)
+
+	_, err = loadGrammar("builtin:speech/boolean", GrammarInline)
+	assert.Error(t, err)
+}
+
+func TestParseGrammarType(t *testing.T) {
+	grammarType, err := ParseGrammarType("inline")
+	assert.NoError(t, err)
+	assert.Equal(t, GrammarInline, grammarType)
+
+	_, err = ParseGrammarType("abnf")
+	assert.Error(t, err)
+
+	_, err = newClientOptions([]Option{WithGrammarType("abnf")})
+	assert.Error(t, err)
+}
+
+func TestLoadBatchKeepsGrammarURIs(t *testing.T) {
+	dir := t.TempDir()
+	manifest := writeTestFile(t, dir, "manifest.jsonl", []byte(`{"audio": "a.wav", "grammar": "builtin:speech/boolean"}`+"\n"))
+
+	items, err := LoadBatch(manifest, BatchItem{Language: "en-US"})
+	assert.NoError(t, err)
+	assert.Equal(t, "builtin:speech/boolean", items[0].Grammar)
+}
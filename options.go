@@ -0,0 +1,41 @@
+package verbio_speech_center
+
+import "golang.org/x/oauth2"
+
+// Option configures optional behaviour of a client at construction time.
+type Option func(*clientOptions) error
+
+type clientOptions struct {
+	targetSampleRate int
+	pacing           Pacing
+	grammarType      GrammarType
+	reconnect        ReconnectPolicy
+	retry            RetryPolicy
+	tokenSource      oauth2.TokenSource
+}
+
+func newClientOptions(opts []Option) (clientOptions, error) {
+	options := clientOptions{
+		pacing:      DefaultPacing,
+		grammarType: GrammarAuto,
+	}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return clientOptions{}, err
+		}
+	}
+	return options, nil
+}
+
+// WithTargetSampleRate makes the Recogniser convert every input file to
+// 16-bit mono LPCM at the given rate (8000 or 16000) before streaming it.
+// Without it, inputs must already be 16-bit LPCM WAV files.
+func WithTargetSampleRate(rate int) Option {
+	return func(o *clientOptions) error {
+		if err := validateTargetRate(rate); err != nil {
+			return err
+		}
+		o.targetSampleRate = rate
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"verbio_speech_center/log"
+)
+
+// Pacing controls how fast audio is streamed to the Recognizer.
+type Pacing struct {
+	// Speed is a multiplier of the real-time rate: 1 streams the audio in real
+	// time, 2 twice as fast. Zero sends the audio as fast as the connection allows.
+	Speed float64
+	// ChunkDuration is the amount of audio sent in each request.
+	ChunkDuration time.Duration
+}
+
+// DefaultPacing streams the audio in real time in 50 ms chunks, which is how
+// a live audio source would feed the Recognizer.
+var DefaultPacing = Pacing{Speed: 1, ChunkDuration: 50 * time.Millisecond}
+
+func (p Pacing) validate() error {
+	if p.Speed < 0 {
+		return fmt.Errorf("invalid pacing speed %v (must be 0 for unthrottled, or positive)", p.Speed)
+	}
+	if p.ChunkDuration <= 0 {
+		return errors.New("pacing chunk duration must be positive")
+	}
+	return nil
+}
+
+// pacer schedules every audio chunk at the moment its audio would have been
+// produced by a source running at the configured speed. Deadlines are taken
+// from the start of the stream, so sleeping inaccuracies never accumulate.
+type pacer struct {
+	speed          float64
+	bytesPerSecond float64
+	chunkSize      int
+	start          time.Time
+	sent           int64
+}
+
+func newPacer(pacing Pacing, sampleRate uint32, channels uint16) *pacer {
+	frameSize := int(channels) * bytesPerSample
+	bytesPerSecond := float64(sampleRate) * float64(frameSize)
+	frames := max(int(pacing.ChunkDuration.Seconds()*float64(sampleRate)), 1)
+	log.Logger.Debugf("Pacing audio [speed=%v] [chunkDuration=%s] [chunkSize=%d bytes]", pacing.Speed, pacing.ChunkDuration, frames*frameSize)
+	return &pacer{
+		speed:          pacing.Speed,
+		bytesPerSecond: bytesPerSecond,
+		chunkSize:      frames * frameSize,
+	}
+}
+
+// wait blocks until a chunk of the given size is due, or ctx is done.
+func (p *pacer) wait(ctx context.Context, chunkSize int) error {
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	p.sent += int64(chunkSize)
+	if p.speed == 0 {
+		return ctx.Err()
+	}
+
+	audioDuration := float64(p.sent) / p.bytesPerSecond / p.speed
+	due := p.start.Add(time.Duration(audioDuration * float64(time.Second)))
+	log.Logger.Tracef("Audio chunk will be sent in %d ms", time.Until(due).Milliseconds())
+
+	timer := time.NewTimer(time.Until(due))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// sentDuration is the duration of the audio sent so far.
+func (p *pacer) sentDuration() time.Duration {
+	return time.Duration(float64(p.sent) / p.bytesPerSecond * float64(time.Second))
+}
+
+// WithPacing sets how fast the Recogniser streams audio. Without it,
+// DefaultPacing is used.
+func WithPacing(pacing Pacing) Option {
+	return func(o *clientOptions) error {
+		if err := pacing.validate(); err != nil {
+			return err
+		}
+		o.pacing = pacing
+		return nil
+	}
+}
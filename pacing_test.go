@@ -0,0 +1,79 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPacerChunkSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		pacing     Pacing
+		sampleRate uint32
+		channels   uint16
+		expected   int
+	}{
+		{"8kHz mono 50ms", DefaultPacing, 8000, 1, 800},
+		{"16kHz mono 50ms", DefaultPacing, 16000, 1, 1600},
+		{"16kHz stereo 20ms", Pacing{Speed: 1, ChunkDuration: 20 * time.Millisecond}, 16000, 2, 1280},
+		{"8kHz mono 100ms", Pacing{Speed: 2, ChunkDuration: 100 * time.Millisecond}, 8000, 1, 1600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, newPacer(tt.pacing, tt.sampleRate, tt.channels).chunkSize)
+		})
+	}
+}
+
+func TestPacerTiming(t *testing.T) {
+	tests := []struct {
+		name     string
+		speed    float64
+		expected time.Duration
+	}{
+		{"real time", 1, 400 * time.Millisecond},
+		{"twice as fast", 2, 200 * time.Millisecond},
+		{"unthrottled", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pacer := newPacer(Pacing{Speed: tt.speed, ChunkDuration: 100 * time.Millisecond}, 16000, 1)
+			start := time.Now()
+			for i := 0; i < 4; i++ {
+				assert.NoError(t, pacer.wait(context.Background(), pacer.chunkSize))
+			}
+			elapsed := time.Since(start)
+			assert.True(t, elapsed >= tt.expected, "elapsed %s, expected at least %s", elapsed, tt.expected)
+			assert.True(t, elapsed < tt.expected+100*time.Millisecond, "elapsed %s, expected about %s", elapsed, tt.expected)
+		})
+	}
+}
+
+func TestPacingValidation(t *testing.T) {
+	assert.NoError(t, DefaultPacing.validate())
+	assert.NoError(t, Pacing{Speed: 0, ChunkDuration: time.Millisecond}.validate())
+	assert.Error(t, Pacing{Speed: -1, ChunkDuration: time.Millisecond}.validate())
+	assert.Error(t, Pacing{Speed: 1}.validate())
+
+	_, err := NewRecogniser("localhost:50051", createTemporaryToken(t), WithPacing(Pacing{Speed: -2, ChunkDuration: time.Second}))
+	assert.Error(t, err)
+}
+
+func TestRecogniseStreamUnthrottled(t *testing.T) {
+	stream := newFakeRecognitionStream(resultResponse(finalResult("hello", 10)))
+	recogniser := newFakeRecogniser(stream, Pacing{Speed: 0, ChunkDuration: 20 * time.Millisecond})
+
+	start := time.Now()
+	res, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 160000)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 8000})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", res)
+	assert.True(t, time.Since(start) < time.Second, "10 seconds of audio should not be paced")
+	assert.Len(t, stream.requests, 1+500+1)
+	assert.Len(t, stream.requests[1].GetAudio(), 320)
+}
@@ -1,119 +1,276 @@
 package verbio_speech_center
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strings"
 	"time"
 	"verbio_speech_center/log"
 	sttv1 "verbio_speech_center/proto/speechcenter/stt"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
-func (r *Recogniser) RecogniseWithGrammar(audioFile string, grammarFile string, language string, wordBoosting []string) (string, error) {
-	log.Logger.Infof("Performing Grammar recognition [audioFile=%s] [grammarFile=%s] [language=%s] [wordBoosting=%v]", audioFile, grammarFile, language, wordBoosting)
-
-	if grammarFile != "" {
-		grammar, err := loadGrammar(grammarFile)
-		if err != nil {
-			return "", errors.New(fmt.Sprintf("error loading grammar: %+v", err))
-		}
+func (r *Recogniser) RecogniseWithGrammar(ctx context.Context, audioFile string, grammarFile string, language string, wordBoosting []string) (string, error) {
+	transcript, err := r.RecogniseTranscriptWithGrammar(ctx, audioFile, grammarFile, language, wordBoosting)
+	if err != nil {
+		return "", err
+	}
+	return transcript.Text(), nil
+}
 
-		configuration := generateGrammarRequest(grammar, language, wordBoosting)
-		return r.performRecognition(audioFile, configuration)
+func (r *Recogniser) RecogniseWithTopic(ctx context.Context, audioFile string, topic string, language string, wordBoosting []string) (string, error) {
+	transcript, err := r.RecogniseTranscriptWithTopic(ctx, audioFile, topic, language, wordBoosting)
+	if err != nil {
+		return "", err
+	}
+	return transcript.Text(), nil
+}
 
-	} else {
-		return "", errors.New("received an empty grammarFile path")
+// RecogniseTranscriptWithGrammar works like RecogniseWithGrammar but keeps
+// the timings, confidences and alternatives of every result.
+func (r *Recogniser) RecogniseTranscriptWithGrammar(ctx context.Context, audioFile string, grammarFile string, language string, wordBoosting []string) (*Transcript, error) {
+	if grammarFile == "" {
+		return nil, recognitionError(ErrInvalidOptions, errors.New("received an empty grammarFile path"))
 	}
+	return r.Recognise(ctx, audioFile, RecognitionOptions{Grammar: grammarFile, Language: language, WordBoosting: wordBoosting})
+}
+
+// RecogniseTranscriptWithTopic works like RecogniseWithTopic but keeps the
+// timings, confidences and alternatives of every result.
+func (r *Recogniser) RecogniseTranscriptWithTopic(ctx context.Context, audioFile string, topic string, language string, wordBoosting []string) (*Transcript, error) {
+	return r.Recognise(ctx, audioFile, RecognitionOptions{Topic: topic, Language: language, WordBoosting: wordBoosting})
 }
 
-func (r *Recogniser) RecogniseWithTopic(audioFile string, topic string, language string, wordBoosting []string) (string, error) {
-	log.Logger.Infof("Performing Topic recognition [audioFile=%s] [topic=%s] [language=%s] [wordBoosting=%v]", audioFile, topic, language, wordBoosting)
-	configuration, err := generateTopicRequest(topic, language, wordBoosting)
+// Recognise recognises audioFile as configured by opts, which are validated
+// before the audio is loaded.
+func (r *Recogniser) Recognise(ctx context.Context, audioFile string, opts RecognitionOptions) (*Transcript, error) {
+	log.Logger.Infof("Performing recognition [audioFile=%s] [grammar=%s] [topic=%s] [language=%s] [wordBoosting=%v]", audioFile, opts.Grammar, opts.Topic, opts.Language, opts.WordBoosting)
+	if err := opts.Validate(); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, fmt.Errorf("invalid recognition options: %w", err))
+	}
+
+	audio, err := loadAudio(audioFile, r.options.targetSampleRate)
+	if err != nil {
+		return nil, audioLoadError(err)
+	}
+	if err := opts.checkChannels(audio.channels); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, err)
+	}
+
+	configuration, err := opts.request(r.options.grammarType, audio.sampleRate, audio.channels)
 	if err != nil {
-		return "", errors.New(fmt.Sprintf("error creating topic request: %+v", err))
+		return nil, err
 	}
-	return r.performRecognition(audioFile, configuration)
+	return r.performRecognition(ctx, audio, configuration, opts.EndOfUtteranceSilence)
 }
 
 type recogResult struct {
-	recognition string
-	err         error
+	results []*sttv1.RecognitionResult
+	err     error
+}
+
+// performRecognition recognises the audio, starting over as many times as the
+// retry policy allows. Once a final result has been received or the stream
+// has been resumed, failures are left to the reconnect policy: starting over
+// would drop the results and reconnections recorded until then.
+func (r *Recogniser) performRecognition(ctx context.Context, audio *audioData, configuration *sttv1.RecognitionStreamingRequest, endOfUtterance time.Duration) (*Transcript, error) {
+	var transcript *Transcript
+	err := r.options.retry.do(ctx, "recognition", func(ctx context.Context) (bool, error) {
+		var err error
+		transcript, err = r.resumeRecognition(ctx, audio, configuration, endOfUtterance)
+		return len(transcript.Segments) == 0 && len(transcript.Reconnects) == 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transcript, nil
+}
+
+// resumeRecognition streams the audio and returns the transcript of the
+// final results in the order they were received. When the stream fails with
+// a retryable error and the reconnect policy allows it, a new stream resumes
+// from the end of the last final result, and the transcripts of every stream
+// are stitched together. When it fails, the transcript stitched until then is
+// returned along with the error.
+func (r *Recogniser) resumeRecognition(ctx context.Context, audio *audioData, configuration *sttv1.RecognitionStreamingRequest, endOfUtterance time.Duration) (*Transcript, error) {
+	policy := r.options.reconnect
+	transcript := &Transcript{Segments: make([]Segment, 0)}
+	offset := time.Duration(0)
+	for attempt := 1; ; attempt++ {
+		part, err := r.performStreamRecognition(ctx, bytes.NewReader(audio.samples[audio.offset(offset):]), configuration, nil, endOfUtterance)
+		if part != nil {
+			for _, segment := range part.Segments {
+				transcript.Segments = append(transcript.Segments, segment.shift(offset))
+			}
+		}
+		if err == nil {
+			if part.StoppedAt > 0 {
+				transcript.StoppedAt = offset + part.StoppedAt
+			}
+			return transcript, nil
+		}
+		if attempt > policy.MaxReconnects || !r.options.retry.retryable(err) || ctx.Err() != nil {
+			return transcript, err
+		}
+
+		if part != nil && len(part.Segments) > 0 {
+			offset += part.Segments[len(part.Segments)-1].End
+		}
+		event := ReconnectEvent{Attempt: attempt, Offset: offset, Error: err.Error()}
+		transcript.Reconnects = append(transcript.Reconnects, event)
+		log.Logger.Warnf("Recognition stream failed, reconnecting [attempt=%d] [offset=%s]: %v", attempt, offset, err)
+		if err := policy.wait(ctx, attempt); err != nil {
+			return transcript, fmt.Errorf("recognition interrupted while reconnecting: %w", err)
+		}
+	}
 }
 
-func (r *Recogniser) performRecognition(audioFile string, configuration *sttv1.RecognitionStreamingRequest) (string, error) {
-	audio, err := loadAudio(audioFile)
+// performStreamRecognition sends the LPCM samples read from audio as they
+// become available and returns the transcript once the reader is drained.
+// Cancelling ctx aborts both the sending and the receiving side of the stream.
+// Every result is passed to handler, when set, as soon as it is received.
+// When endOfUtterance is positive, sending stops early once a final result
+// has been received and the trailing silence reaches it. When the stream
+// fails, the transcript of the final results received until then is returned
+// along with the error.
+func (r *Recogniser) performStreamRecognition(ctx context.Context, audio io.Reader, configuration *sttv1.RecognitionStreamingRequest, handler ResultHandler, endOfUtterance time.Duration) (*Transcript, error) {
+	ctx, release, err := r.sessions.begin(ctx)
 	if err != nil {
-		return "", errors.New(fmt.Sprintf("error loading audio file %+v", err))
+		return nil, err
 	}
+	defer release()
+	halves := newDuplex(ctx)
+	defer halves.cancel()
 
-	r.streamClient, err = r.client.StreamingRecognize(context.Background(), grpc.WaitForReady(true))
+	stream, err := r.client.StreamingRecognize(halves.ctx, grpc.WaitForReady(true))
 	if err != nil {
-		return "", errors.New(fmt.Sprintf("error obtaining streaming client: %+v", err))
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("error obtaining streaming client: %w", ctx.Err())
+		}
+		return nil, recognitionFailure(fmt.Errorf("error obtaining streaming client: %w", err))
+	}
+
+	parameters := configuration.GetConfig().GetParameters()
+	session := &recognitionSession{
+		stream:  stream,
+		pacer:   newPacer(r.options.pacing, parameters.GetPcm().GetSampleRateHz(), uint16(max(parameters.GetAudioChannelsNumber(), 1))),
+		handler: handler,
+	}
+	if endOfUtterance > 0 {
+		session.endOfUtterance = endOfUtterance
+		session.stop = make(chan struct{})
 	}
 
-	c := make(chan recogResult)
+	received := make(chan recogResult, 1)
 	go func() {
-		c = r.collectResponses(c)
+		recog := session.collectResponses()
+		if recog.err != nil {
+			halves.fail(fmt.Errorf("got error during recognition: %w", recog.err))
+		}
+		received <- recog
 	}()
 
-	if err = r.sendAudio(configuration, audio); err != nil {
-		return "", err
-	}
+	sendErr := halves.sent(session.sendAudio(halves.ctx, configuration, audio))
 
 	log.Logger.Info("Waiting for recognition to finish")
-	recog := <-c
-	if recog.err != nil {
-		return "", errors.New(fmt.Sprintf("got error during recognition: %+v", recog.err))
+	recog := <-received
+	if ctx.Err() != nil {
+		if sendErr != nil {
+			return nil, fmt.Errorf("recognition interrupted while sending audio: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("recognition interrupted while receiving results: %w", ctx.Err())
+	}
+	if err := halves.err; err != nil {
+		return newTranscript(recog.results), recognitionFailure(err)
 	}
 
-	return recog.recognition, nil
+	transcript := newTranscript(recog.results)
+	transcript.StoppedAt = session.stoppedAt
+	return transcript, nil
+}
+
+// newServiceRecognitionError turns an error reported by the service in a
+// response into a RecognitionError.
+func newServiceRecognitionError(e *sttv1.RecognitionError) *RecognitionError {
+	return &RecognitionError{
+		Kind:     reasonKind(e.GetReason()),
+		Code:     codes.Unknown,
+		Reason:   e.GetReason(),
+		Domain:   e.GetDomain(),
+		Metadata: e.GetMetadata(),
+	}
 }
 
-func (r *Recogniser) collectResponses(c chan recogResult) chan recogResult {
-	recog := make([]string, 0)
+// collectResponses receives results until the stream ends, returning the
+// final ones along with the error that ended the stream, if any.
+func (s *recognitionSession) collectResponses() recogResult {
+	finals := make([]*sttv1.RecognitionResult, 0)
 	log.Logger.Debugf("> Waiting for responses ...")
 	totalAudioLengthInMs := float32(0)
 	for {
 		resp := &sttv1.RecognitionStreamingResponse{}
-		err := r.streamClient.RecvMsg(resp)
+		err := s.stream.RecvMsg(resp)
 		if err != nil {
 			if err == io.EOF {
 				log.Logger.Debugf("Got EOF")
-				c <- recogResult{recognition: strings.Join(recog, " "), err: nil}
-				break
+				log.Logger.Debugf("< all responses received")
+				return recogResult{results: finals, err: nil}
 			} else {
 				log.Logger.Debugf("Got result")
-				c <- recogResult{recognition: "", err: err}
-				break
+				return recogResult{results: finals, err: err}
 			}
 		} else {
 			// Check for errors in response
 			if resp.GetError() != nil {
-				errMsg := fmt.Sprintf("recognition error: %s (domain: %s)", resp.GetError().Reason, resp.GetError().Domain)
-				c <- recogResult{recognition: "", err: errors.New(errMsg)}
-				break
+				return recogResult{results: finals, err: newServiceRecognitionError(resp.GetError())}
 			}
 			// Extract transcript from result
 			if result := resp.GetResult(); result != nil && len(result.Alternatives) > 0 {
+				silence := s.calculateEndOfUtteranceSilence(result, totalAudioLengthInMs)
 				log.Logger.Debugf("Got partial recog: %s (is_final: %v) (silence: %d ms)",
-					result.Alternatives[0].Transcript, result.IsFinal, r.calculateEndOfUtteranceSilence(result, totalAudioLengthInMs))
+					result.Alternatives[0].Transcript, result.IsFinal, silence)
+				if s.handler != nil {
+					s.handler(newRecognitionEvent(result, totalAudioLengthInMs, silence))
+				}
 				if result.IsFinal {
-					recog = append(recog, result.Alternatives[0].Transcript)
+					finals = append(finals, result)
 					totalAudioLengthInMs += result.Duration
 				}
+				if len(finals) > 0 {
+					s.checkEndOfUtterance(time.Duration(silence) * time.Millisecond)
+				}
 			}
 		}
 	}
-	log.Logger.Debugf("< all responses received")
-	return c
 }
 
-func (r *Recogniser) calculateEndOfUtteranceSilence(result *sttv1.RecognitionResult, totalAudioLengthInMs float32) int32 {
+// checkEndOfUtterance asks the sending side to stop once silence reaches the
+// end-of-utterance threshold of the session, if it has one.
+func (s *recognitionSession) checkEndOfUtterance(silence time.Duration) {
+	if s.stop == nil || silence < s.endOfUtterance {
+		return
+	}
+	s.stopOnce.Do(func() {
+		log.Logger.Infof("End of utterance detected after %d ms of silence", silence.Milliseconds())
+		close(s.stop)
+	})
+}
+
+// stopRequested tells whether the end of the utterance has been detected.
+func (s *recognitionSession) stopRequested() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *recognitionSession) calculateEndOfUtteranceSilence(result *sttv1.RecognitionResult, totalAudioLengthInMs float32) int32 {
 	words := result.Alternatives[0].Words
 	finalSilenceInMs := int32(0)
 	if len(words) > 0 {
@@ -122,51 +279,63 @@ func (r *Recogniser) calculateEndOfUtteranceSilence(result *sttv1.RecognitionRes
 	return finalSilenceInMs
 }
 
-func (r *Recogniser) sendAudio(configuration *sttv1.RecognitionStreamingRequest, audio []byte) error {
+func (s *recognitionSession) sendAudio(ctx context.Context, configuration *sttv1.RecognitionStreamingRequest, audio io.Reader) error {
 	log.Logger.Info("Sending configuration request")
-	if err := r.streamClient.Send(configuration); err != nil {
-		return errors.New(fmt.Sprintf("error sending configuration request: %+v", err))
+	if err := s.stream.Send(configuration); err != nil {
+		return fmt.Errorf("error sending configuration request: %w", err)
 	}
 
-	if err := r.sendAudioStream(audio); err != nil {
+	if err := s.sendAudioStream(ctx, audio); err != nil {
 		return err
 	}
 
-	if err := r.streamClient.CloseSend(); err != nil {
-		return errors.New(fmt.Sprintf("error closing send: %+v", err))
+	if err := s.stream.CloseSend(); err != nil {
+		return fmt.Errorf("error closing send: %w", err)
 	}
 	return nil
 }
 
-func (r *Recogniser) sendAudioStream(audio []byte) error {
+func (s *recognitionSession) sendAudioStream(ctx context.Context, audio io.Reader) error {
 	log.Logger.Info("Sending audio stream.")
-	if err := r.sendAudioChunks(audio); err != nil {
-		return errors.New(fmt.Sprintf("error sending Audio chunks: %+v", err))
+	if err := s.sendAudioChunks(ctx, audio); err != nil {
+		return fmt.Errorf("error sending Audio chunks: %w", err)
 	}
-	if err := r.sendEndOfStream(); err != nil {
-		return errors.New(fmt.Sprintf("error sending END_OF_STREAM event: %+v", err))
+	if err := s.sendEndOfStream(); err != nil {
+		return fmt.Errorf("error sending END_OF_STREAM event: %w", err)
 	}
 	return nil
 }
 
-func (r *Recogniser) sendAudioChunks(audio []byte) error {
-	const chunkSize = 800
-	for i := 0; i < len(audio); i += chunkSize {
-		end := i + chunkSize
-		if end > len(audio) {
-			end = len(audio)
+// sendAudioChunks reads the audio in chunks of the size set by the pacer and
+// sends each of them once the pacer says it is due. It returns early when the
+// end of the utterance is detected, recording how much audio was sent.
+func (s *recognitionSession) sendAudioChunks(ctx context.Context, audio io.Reader) error {
+	buffer := make([]byte, s.pacer.chunkSize)
+	for {
+		if s.stopRequested() {
+			s.stoppedAt = s.pacer.sentDuration()
+			log.Logger.Infof("Stopped streaming at %s of audio", s.stoppedAt)
+			return nil
+		}
+		n, err := io.ReadFull(audio, buffer)
+		if n > 0 {
+			if err := s.pacer.wait(ctx, n); err != nil {
+				return err
+			}
+			if err := s.sendAudioRequest(ctx, buffer[:n]); err != nil {
+				return fmt.Errorf("error sending audio chunk: %w", err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
 		}
-
-		audioChunk := audio[i:end]
-		err := r.SendAudioRequest(audioChunk)
 		if err != nil {
-			return errors.New(fmt.Sprintf("error sending audio chunk: %+v", err))
+			return recognitionError(ErrInvalidAudio, fmt.Errorf("error reading audio: %w", err))
 		}
 	}
-	return nil
 }
 
-func (r *Recogniser) sendEndOfStream() error {
+func (s *recognitionSession) sendEndOfStream() error {
 	// Send END_OF_STREAM event
 	log.Logger.Info("Sending END_OF_STREAM event")
 	endOfStreamRequest := &sttv1.RecognitionStreamingRequest{
@@ -176,109 +345,20 @@ func (r *Recogniser) sendEndOfStream() error {
 			},
 		},
 	}
-	return r.streamClient.Send(endOfStreamRequest)
+	return s.stream.Send(endOfStreamRequest)
 }
 
-func (r *Recogniser) SendAudioRequest(audioChunk []byte) error {
+// sendAudioRequest sends a single chunk of audio right away. Pacing is
+// applied by the caller.
+func (s *recognitionSession) sendAudioRequest(ctx context.Context, audioChunk []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	log.Logger.Tracef("Sending audio chunk (size: %d bytes)", len(audioChunk))
-	const sampleRate = int32(8000)
-	endOfRequest := time.Now().Add(time.Duration(float64(len(audioChunk)) / float64(sampleRate) * float64(time.Second)))
 	audioRequest := &sttv1.RecognitionStreamingRequest{
 		RecognitionRequest: &sttv1.RecognitionStreamingRequest_Audio{
 			Audio: audioChunk,
 		},
 	}
-
-	log.Logger.Tracef("Audio chunk will be sent until %d", time.Until(endOfRequest).Milliseconds())
-	time.Sleep(time.Until(endOfRequest))
-	return r.streamClient.Send(audioRequest)
-}
-
-func generateGrammarRequest(grammar []byte, language string, wordBoosting []string) *sttv1.RecognitionStreamingRequest {
-	sampleRate := uint32(8000)
-
-	resource := &sttv1.RecognitionResource{
-		Resource: &sttv1.RecognitionResource_Grammar{
-			Grammar: &sttv1.GrammarResource{
-				Grammar: &sttv1.GrammarResource_CompiledGrammar{
-					CompiledGrammar: grammar,
-				},
-			},
-		},
-	}
-
-	config := &sttv1.RecognitionConfig{
-		Parameters: &sttv1.RecognitionParameters{
-			Language: language,
-			AudioEncoding: &sttv1.RecognitionParameters_Pcm{
-				Pcm: &sttv1.PCM{
-					SampleRateHz: sampleRate,
-				},
-			},
-			WordBoosting: wordBoosting,
-		},
-		Resource: resource,
-		Version:  sttv1.RecognitionConfig_V2,
-	}
-
-	return &sttv1.RecognitionStreamingRequest{
-		RecognitionRequest: &sttv1.RecognitionStreamingRequest_Config{
-			Config: config,
-		},
-	}
-}
-
-func generateTopicRequest(topic string, language string, wordBoosting []string) (*sttv1.RecognitionStreamingRequest, error) {
-	topicLower := strings.ToLower(topic)
-	if topicLower != "generic" {
-		return nil, errors.New(fmt.Sprintf("unrecognized topic: %s (only 'generic' is supported)", topic))
-	}
-
-	// Default sample rate for speech recognition (16kHz is common)
-	sampleRate := uint32(8000)
-
-	log.Logger.Infof("Performing recognition with topic: %s", topicLower)
-	resource := &sttv1.RecognitionResource{
-		Resource: &sttv1.RecognitionResource_Topic_{
-			Topic: sttv1.RecognitionResource_GENERIC,
-		},
-	}
-
-	config := &sttv1.RecognitionConfig{
-		Parameters: &sttv1.RecognitionParameters{
-			Language: language,
-			AudioEncoding: &sttv1.RecognitionParameters_Pcm{
-				Pcm: &sttv1.PCM{
-					SampleRateHz: sampleRate,
-				},
-			},
-			WordBoosting: wordBoosting,
-		},
-		Resource: resource,
-		Version:  sttv1.RecognitionConfig_V2,
-	}
-
-	return &sttv1.RecognitionStreamingRequest{
-		RecognitionRequest: &sttv1.RecognitionStreamingRequest_Config{
-			Config: config,
-		},
-	}, nil
-}
-
-func loadAudio(file string) ([]byte, error) {
-	contents, err := os.ReadFile(file)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error reading audio file: %+v", err))
-	}
-
-	return contents, nil
-}
-
-func loadGrammar(file string) ([]byte, error) {
-	contents, err := os.ReadFile(file)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error reading grammar file: %+v", err))
-	}
-
-	return contents, nil
+	return s.stream.Send(audioRequest)
 }
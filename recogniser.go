@@ -1,6 +1,7 @@
 package verbio_speech_center
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -16,52 +17,72 @@ import (
 	"google.golang.org/grpc/credentials/oauth"
 )
 
+// Recogniser is safe for concurrent use: every recognition runs on its own
+// stream over the shared connection.
 type Recogniser struct {
-	conn         *grpc.ClientConn
-	client       pb.RecognizerClient
-	streamClient grpc.BidiStreamingClient[pb.RecognitionStreamingRequest, pb.RecognitionStreamingResponse]
+	conn     *grpc.ClientConn
+	client   pb.RecognizerClient
+	options  clientOptions
+	sessions sessionTracker
 }
 
-func NewRecogniser(url string, tokenFile string) (*Recogniser, error) {
+func NewRecogniser(url string, tokenFile string, opts ...Option) (*Recogniser, error) {
 	if err := validateURL(url); err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	token, err := loadToken(tokenFile)
-	log.Logger.Infof("Loaded token from file: [%s]", tokenFile)
+	options, err := newClientOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option: %w", err)
+	}
+
+	tokens, err := options.credentials(tokenFile)
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err := initConnection(url, token)
+	conn, err := initConnection(url, tokens)
 	log.Logger.Infof("Established connection to the URL: [%s]", url)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error establishing connection: %+v", err))
+		return nil, fmt.Errorf("error establishing connection: %w", err)
 	}
 
 	client := pb.NewRecognizerClient(conn)
 	return &Recogniser{
-		conn:   conn,
-		client: client,
+		conn:    conn,
+		client:  client,
+		options: options,
 	}, nil
 }
 
+// Close cancels the recognitions in flight, waits for them to return and
+// closes the connection. When recognitions had to be cancelled, the error
+// returned matches context.Canceled.
 func (r *Recogniser) Close() error {
-	return r.conn.Close()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	return errors.Join(r.sessions.shutdown(cancelled), r.conn.Close())
+}
+
+// Shutdown stops accepting new recognitions and waits for the ones in flight
+// to finish before closing the connection. If ctx is done first, the
+// remaining recognitions are cancelled and the context error is returned.
+func (r *Recogniser) Shutdown(ctx context.Context) error {
+	return errors.Join(r.sessions.shutdown(ctx), r.conn.Close())
 }
 
-func initConnection(url string, token string) (*grpc.ClientConn, error) {
+func initConnection(url string, tokens oauth2.TokenSource) (*grpc.ClientConn, error) {
 	log.Logger.Debugf("Initializing connection to the URL: [%s]", url)
 	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
 			InsecureSkipVerify: false,
 			MinVersion:         tls.VersionTLS13,
 		})),
-		grpc.WithPerRPCCredentials(oauth.TokenSource{TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token, TokenType: "Bearer"})}),
+		grpc.WithPerRPCCredentials(oauth.TokenSource{TokenSource: tokens}),
 	}
 	conn, err := grpc.NewClient(url, opts...)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error in grpc dial: %+v", err))
+		return nil, fmt.Errorf("error in grpc dial: %w", err)
 	}
 
 	return conn, nil
@@ -70,7 +91,7 @@ func initConnection(url string, token string) (*grpc.ClientConn, error) {
 func loadToken(file string) (string, error) {
 	contents, err := os.ReadFile(file)
 	if err != nil {
-		return "", errors.New(fmt.Sprintf("error reading token file: %+v", err))
+		return "", fmt.Errorf("error reading token file: %w", err)
 	}
 	return strings.TrimSpace(string(contents)), nil
 }
@@ -14,18 +14,24 @@ func TestNewRecogniser(t *testing.T) {
 	assert.NotNil(t, recognizer)
 	assert.NotNil(t, recognizer.conn)
 	assert.NotNil(t, recognizer.client)
-	assert.Nil(t, recognizer.streamClient)
 
 	err = recognizer.Close()
 	assert.NoError(t, err)
 }
 
 func createTemporaryToken(t *testing.T) string {
-	tmpDir := t.TempDir()
-	tokenFile := filepath.Join(tmpDir, "token.txt")
-	err := os.WriteFile(tokenFile, []byte("test-token"), 0600)
-	assert.NoError(t, err)
-	return tokenFile
+	return createTemporaryTokenWith(t, "test-token")
+}
+
+func createTemporaryTokenWith(t *testing.T, token string) string {
+	return writeTestFile(t, t.TempDir(), "token.txt", []byte(token))
+}
+
+// writeTestFile writes a file named name in dir and returns its path.
+func writeTestFile(t *testing.T, dir string, name string, contents []byte) string {
+	file := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(file, contents, 0600))
+	return file
 }
 
 func TestNewRecogniserErrors(t *testing.T) {
@@ -0,0 +1,164 @@
+package verbio_speech_center
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+)
+
+// DefaultConfigVersion is the version of the recognition configuration used
+// when RecognitionOptions leaves it empty.
+const DefaultConfigVersion = "V2"
+
+// RecognitionOptions describes what a recognition listens for and how the
+// service should process it.
+type RecognitionOptions struct {
+	// Grammar is the path or URI of a grammar. When it is empty, Topic is used.
+	Grammar  string
+	Topic    string
+	Language string
+	// WordBoosting lists words the recogniser should favour.
+	WordBoosting []string
+	// BoostingList, when set, adds its common terms and those of the section
+	// of Language to WordBoosting.
+	BoostingList *BoostingList
+	// EnableFormatting asks the service to format the transcript.
+	EnableFormatting bool
+	// EnableDiarization asks the service to tell speakers apart.
+	EnableDiarization bool
+	// Channels is the number of channels of the audio. Zero takes it from the
+	// audio; otherwise it must match the audio, and it describes headerless
+	// streams, which are taken as mono without it.
+	Channels uint16
+	// Labels are attached to the recognition request.
+	Labels []string
+	// Version is the version of the recognition configuration, V1 or V2. It
+	// defaults to DefaultConfigVersion.
+	Version string
+	// EndOfUtteranceSilence, when positive, stops sending audio once a final
+	// result has been received and the silence after the last recognised word
+	// reaches it. The transcript then tells where streaming stopped.
+	EndOfUtteranceSilence time.Duration
+}
+
+// ConfigVersions lists the versions of the recognition configuration the
+// service knows, in the order of the proto enum.
+func ConfigVersions() []string {
+	return enumNames(sttv1.RecognitionConfig_ConfigVersion_name)
+}
+
+func parseConfigVersion(name string) (sttv1.RecognitionConfig_ConfigVersion, error) {
+	if name == "" {
+		name = DefaultConfigVersion
+	}
+	number, ok := sttv1.RecognitionConfig_ConfigVersion_value[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unrecognized config version: %s (must be one of %s)", name, strings.Join(ConfigVersions(), ", "))
+	}
+	return sttv1.RecognitionConfig_ConfigVersion(number), nil
+}
+
+// Validate reports the first problem with o that would make the service
+// reject the recognition, without contacting it.
+func (o RecognitionOptions) Validate() error {
+	if o.Grammar == "" && o.Topic == "" {
+		return errors.New("either a grammar or a topic must be specified for recognition")
+	}
+	if o.Grammar == "" {
+		if _, err := ParseTopic(o.Topic); err != nil {
+			return err
+		}
+	}
+	if err := validateWordBoosting(o.boostedWords()); err != nil {
+		return err
+	}
+	for _, label := range o.Labels {
+		if strings.TrimSpace(label) == "" {
+			return errors.New("labels cannot be empty")
+		}
+	}
+	if _, err := parseConfigVersion(o.Version); err != nil {
+		return err
+	}
+	if o.EndOfUtteranceSilence < 0 {
+		return errors.New("end-of-utterance silence cannot be negative")
+	}
+	return nil
+}
+
+// boostedWords returns WordBoosting followed by the terms of BoostingList
+// that it does not already hold.
+func (o RecognitionOptions) boostedWords() []string {
+	if o.BoostingList == nil {
+		return o.WordBoosting
+	}
+	words := append([]string(nil), o.WordBoosting...)
+	seen := make(map[string]bool, len(words))
+	for _, word := range words {
+		seen[strings.ToLower(word)] = true
+	}
+	for _, word := range o.BoostingList.Words(o.Language) {
+		if !seen[strings.ToLower(word)] {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// checkChannels verifies that the channels of the audio agree with o.
+func (o RecognitionOptions) checkChannels(channels uint16) error {
+	if o.Channels != 0 && o.Channels != channels {
+		return fmt.Errorf("audio has %d channels but %d were requested", channels, o.Channels)
+	}
+	return nil
+}
+
+// request builds the configuration message that opens a recognition stream
+// for audio with the given sample rate and channels.
+func (o RecognitionOptions) request(grammarType GrammarType, sampleRate uint32, channels uint16) (*sttv1.RecognitionStreamingRequest, error) {
+	version, err := parseConfigVersion(o.Version)
+	if err != nil {
+		return nil, recognitionError(ErrInvalidOptions, err)
+	}
+
+	resource := &sttv1.RecognitionResource{}
+	if o.Grammar != "" {
+		grammar, err := loadGrammar(o.Grammar, grammarType)
+		if err != nil {
+			return nil, recognitionError(ErrInvalidGrammar, fmt.Errorf("error loading grammar: %w", err))
+		}
+		resource.Resource = &sttv1.RecognitionResource_Grammar{Grammar: grammar}
+	} else {
+		topic, err := ParseTopic(o.Topic)
+		if err != nil {
+			return nil, recognitionError(ErrInvalidOptions, fmt.Errorf("error creating topic request: %w", err))
+		}
+		resource.Resource = &sttv1.RecognitionResource_Topic_{Topic: topic}
+	}
+
+	config := &sttv1.RecognitionConfig{
+		Parameters: &sttv1.RecognitionParameters{
+			Language: o.Language,
+			AudioEncoding: &sttv1.RecognitionParameters_Pcm{
+				Pcm: &sttv1.PCM{
+					SampleRateHz: sampleRate,
+				},
+			},
+			EnableFormatting:    o.EnableFormatting,
+			EnableDiarization:   o.EnableDiarization,
+			AudioChannelsNumber: uint32(channels),
+			WordBoosting:        o.boostedWords(),
+		},
+		Resource: resource,
+		Label:    o.Labels,
+		Version:  version,
+	}
+
+	return &sttv1.RecognitionStreamingRequest{
+		RecognitionRequest: &sttv1.RecognitionStreamingRequest_Config{
+			Config: config,
+		},
+	}, nil
+}
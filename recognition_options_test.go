@@ -0,0 +1,98 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecognitionOptionsValidate(t *testing.T) {
+	assert.NoError(t, RecognitionOptions{Topic: "Banking"}.Validate())
+	assert.NoError(t, RecognitionOptions{Grammar: "builtin:speech/boolean", Version: "v1"}.Validate())
+
+	tests := []struct {
+		name    string
+		options RecognitionOptions
+		errMsg  string
+	}{
+		{"no resource", RecognitionOptions{Language: "en-US"}, "either a grammar or a topic"},
+		{"unknown topic", RecognitionOptions{Topic: "weather"}, "unrecognized topic"},
+		{"empty boosted word", RecognitionOptions{Topic: "generic", WordBoosting: []string{"verbio", " "}}, "word boosting"},
+		{"empty label", RecognitionOptions{Topic: "generic", Labels: []string{""}}, "labels"},
+		{"unknown version", RecognitionOptions{Topic: "generic", Version: "V9"}, "V1, V2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestRecognitionOptionsRequest(t *testing.T) {
+	options := RecognitionOptions{
+		Topic:             "telco",
+		Language:          "es-ES",
+		WordBoosting:      []string{"verbio"},
+		EnableFormatting:  true,
+		EnableDiarization: true,
+		Labels:            []string{"campaign-1"},
+	}
+	request, err := options.request(GrammarAuto, 8000, 2)
+	assert.NoError(t, err)
+
+	config := request.GetConfig()
+	assert.Equal(t, sttv1.RecognitionResource_TELCO, config.GetResource().GetTopic())
+	assert.Equal(t, sttv1.RecognitionConfig_V2, config.GetVersion())
+	assert.Equal(t, []string{"campaign-1"}, config.GetLabel())
+	parameters := config.GetParameters()
+	assert.Equal(t, "es-ES", parameters.GetLanguage())
+	assert.Equal(t, []string{"verbio"}, parameters.GetWordBoosting())
+	assert.True(t, parameters.GetEnableFormatting())
+	assert.True(t, parameters.GetEnableDiarization())
+	assert.Equal(t, uint32(2), parameters.GetAudioChannelsNumber())
+
+	options.Version = "V1"
+	request, err = options.request(GrammarAuto, 8000, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, sttv1.RecognitionConfig_V1, request.GetConfig().GetVersion())
+}
+
+func TestRecogniseInvalidOptionsOpensNoStream(t *testing.T) {
+	stream := newFakeRecognitionStream()
+	recogniser := newFakeRecogniser(stream, DefaultPacing)
+
+	_, err := recogniser.Recognise(context.Background(), "missing.wav", RecognitionOptions{Topic: "weather"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid recognition options")
+
+	_, err = recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 160)), StreamConfig{SampleRate: 8000})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid recognition options")
+	assert.Empty(t, stream.requests)
+}
+
+func TestRecogniseChannelsMustMatchAudio(t *testing.T) {
+	stream := newFakeRecognitionStream()
+	recogniser := newFakeRecogniser(stream, DefaultPacing)
+	audioFile := createTemporaryWav(t, 8000, 16, 2, make([]int, 200))
+
+	_, err := recogniser.Recognise(context.Background(), audioFile, RecognitionOptions{Topic: "generic", Channels: 1})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "audio has 2 channels but 1 were requested")
+	assert.Empty(t, stream.requests)
+}
+
+func TestRecogniseStreamRawChannels(t *testing.T) {
+	stream := newFakeRecognitionStream(resultResponse(finalResult("hello", 1)))
+	recogniser := newFakeRecogniser(stream, DefaultPacing)
+
+	config := StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic", Channels: 2}, SampleRate: 8000}
+	_, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 640)), config)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), stream.requests[0].GetConfig().GetParameters().GetAudioChannelsNumber())
+}
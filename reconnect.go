@@ -0,0 +1,74 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ReconnectPolicy controls how the recognition of an audio file recovers from
+// a stream that fails partway through. A stream is reopened when it fails
+// with one of the retryable statuses of the RetryPolicy of the client, which
+// default to DefaultRetryableCodes. The zero value never reconnects.
+type ReconnectPolicy struct {
+	// MaxReconnects is the number of times a recognition may open a new
+	// stream after the previous one failed.
+	MaxReconnects int
+	// Backoff is the wait before the first reconnection, doubled before every
+	// further one.
+	Backoff time.Duration
+}
+
+func (p ReconnectPolicy) validate() error {
+	if p.MaxReconnects < 0 {
+		return errors.New("maximum number of reconnections cannot be negative")
+	}
+	if p.Backoff < 0 {
+		return errors.New("reconnection backoff cannot be negative")
+	}
+	return nil
+}
+
+// wait sleeps for the backoff of the given reconnection attempt, or until ctx
+// is done.
+func (p ReconnectPolicy) wait(ctx context.Context, attempt int) error {
+	delay := p.Backoff << (attempt - 1)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithReconnect makes the Recogniser resume the recognition of audio files on
+// a new stream when the current one fails with a retryable error. The new
+// stream is sent the same configuration and the audio that follows the last
+// final result, and the transcript records every reconnection. Audio read
+// from a stream cannot be resent and is never resumed.
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(o *clientOptions) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		o.reconnect = policy
+		return nil
+	}
+}
+
+// ReconnectEvent records a recognition stream that failed and was resumed on
+// a new one.
+type ReconnectEvent struct {
+	// Attempt counts the reconnections of the recognition, starting at 1.
+	Attempt int
+	// Offset is the position of the audio the new stream started from: the
+	// end of the last final result received before the failure.
+	Offset time.Duration
+	// Error is the failure of the previous stream.
+	Error string
+}
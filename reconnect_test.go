@@ -0,0 +1,125 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyServer transcribes audio like transcribeRuns, but its first failures
+// streams send a final result for the first second of audio once they have
+// received two, and then fail with code.
+func flakyServer(failures int, code codes.Code) *fakeRecognizerServer {
+	return &fakeRecognizerServer{
+		transcribe: transcribeRuns,
+		onAudio: func(n int, stream recognizeStream, sampleRate uint32, audio []byte) error {
+			second := int(sampleRate) * bytesPerSample
+			if n > failures || len(audio) < 2*second {
+				return nil
+			}
+			if err := stream.Send(resultResponse(transcribeRuns(sampleRate, audio[:second]))); err != nil {
+				return err
+			}
+			return status.Error(code, "stream dropped")
+		},
+	}
+}
+
+func spokenWav(t *testing.T, seconds int) string {
+	audio := spokenAudio(seconds)
+	samples := make([]int, len(audio.samples)/bytesPerSample)
+	for i := range samples {
+		samples[i] = int(int16(binary.LittleEndian.Uint16(audio.samples[i*bytesPerSample:])))
+	}
+	return createTemporaryWav(t, int(audio.sampleRate), 16, 1, samples)
+}
+
+func TestRecogniseReconnects(t *testing.T) {
+	server := flakyServer(2, codes.Unavailable)
+	recogniser := newFakeServerRecogniser(t, server, WithReconnect(ReconnectPolicy{MaxReconnects: 3, Backoff: time.Millisecond}))
+	defer recogniser.Close()
+
+	transcript, err := recogniser.Recognise(context.Background(), spokenWav(t, 5), RecognitionOptions{Topic: "generic"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, server.streams)
+
+	assert.Equal(t, "w1 w2 w3 w4 w5", transcript.Text())
+	words := transcript.Words()
+	assert.Len(t, words, 5)
+	for i, word := range words {
+		assert.Equal(t, fmt.Sprintf("w%d", i+1), word.Text)
+		assert.InDelta(t, float64(time.Duration(i)*time.Second+200*time.Millisecond), float64(word.Start), float64(time.Millisecond))
+	}
+	assert.InDelta(t, float64(2*time.Second), float64(transcript.Segments[2].Start), float64(time.Millisecond))
+	assert.InDelta(t, float64(5*time.Second), float64(transcript.Segments[2].End), float64(time.Millisecond))
+
+	assert.Len(t, transcript.Reconnects, 2)
+	assert.Equal(t, 1, transcript.Reconnects[0].Attempt)
+	assert.InDelta(t, float64(time.Second), float64(transcript.Reconnects[0].Offset), float64(time.Millisecond))
+	assert.Contains(t, transcript.Reconnects[0].Error, "stream dropped")
+	assert.Equal(t, 2, transcript.Reconnects[1].Attempt)
+	assert.InDelta(t, float64(2*time.Second), float64(transcript.Reconnects[1].Offset), float64(time.Millisecond))
+}
+
+func TestRecogniseReconnectsExhausted(t *testing.T) {
+	server := flakyServer(2, codes.Unavailable)
+	recogniser := newFakeServerRecogniser(t, server, WithReconnect(ReconnectPolicy{MaxReconnects: 1}))
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 5), RecognitionOptions{Topic: "generic"})
+	assert.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 2, server.streams)
+}
+
+func TestRecogniseDoesNotReconnectOnPermanentErrors(t *testing.T) {
+	for _, code := range []codes.Code{codes.InvalidArgument, codes.DeadlineExceeded} {
+		server := flakyServer(1, code)
+		recogniser := newFakeServerRecogniser(t, server, WithReconnect(ReconnectPolicy{MaxReconnects: 3}))
+		defer recogniser.Close()
+
+		_, err := recogniser.Recognise(context.Background(), spokenWav(t, 3), RecognitionOptions{Topic: "generic"})
+		assert.Error(t, err)
+		assert.Equal(t, code, status.Code(err))
+		assert.Equal(t, 1, server.streams)
+	}
+}
+
+func TestRecogniseReconnectsOnRetryCodes(t *testing.T) {
+	server := flakyServer(1, codes.Internal)
+	recogniser := newFakeServerRecogniser(t, server, WithReconnect(ReconnectPolicy{MaxReconnects: 1}), WithRetry(RetryPolicy{Codes: []codes.Code{codes.Internal}}))
+	defer recogniser.Close()
+
+	transcript, err := recogniser.Recognise(context.Background(), spokenWav(t, 3), RecognitionOptions{Topic: "generic"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.streams)
+	assert.Len(t, transcript.Reconnects, 1)
+}
+
+func TestRecogniseWithoutReconnectPolicy(t *testing.T) {
+	server := flakyServer(1, codes.Unavailable)
+	recogniser := newFakeServerRecogniser(t, server, WithReconnect(ReconnectPolicy{}))
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 3), RecognitionOptions{Topic: "generic"})
+	assert.Error(t, err)
+	assert.Equal(t, 1, server.streams)
+}
+
+func TestReconnectPolicyWait(t *testing.T) {
+	policy := ReconnectPolicy{Backoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, policy.wait(ctx, 1))
+
+	assert.NoError(t, ReconnectPolicy{Backoff: time.Millisecond}.wait(context.Background(), 3))
+
+	_, err := newClientOptions([]Option{WithReconnect(ReconnectPolicy{MaxReconnects: -1})})
+	assert.Error(t, err)
+}
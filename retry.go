@@ -0,0 +1,158 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"time"
+	"verbio_speech_center/log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetryableCodes are the statuses retried, and the ones a failed
+// recognition stream is reopened after, when a RetryPolicy does not list its
+// own: the service could not be reached or turned the request away.
+var DefaultRetryableCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted}
+
+// RetryPolicy controls how a recognition or synthesis that fails with a
+// transient gRPC status is started again from the beginning. The zero value
+// never retries.
+//
+// A request is only retried when its input can be sent again as it was: the
+// audio of a file or the text to synthesize. Audio read from a stream is
+// retried only if the failure came before any of it was read, and a
+// recognition only until it receives a final result or reconnects.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is tried, the first one
+	// included.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt, multiplied by
+	// Multiplier before every further one up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Multiplier defaults to 2.
+	Multiplier float64
+	// Jitter spreads every wait at random by up to this fraction of it, so
+	// that clients failing together do not retry together. It is between 0
+	// and 1.
+	Jitter float64
+	// Budget, when set, bounds the time from the first attempt after which no
+	// further attempt is started.
+	Budget time.Duration
+	// Codes are the retryable statuses. They default to
+	// DefaultRetryableCodes.
+	Codes []codes.Code
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 0 {
+		return errors.New("maximum number of attempts cannot be negative")
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return errors.New("retry backoff cannot be negative")
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("retry backoff multiplier %g must be at least 1", p.Multiplier)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter %g must be between 0 and 1", p.Jitter)
+	}
+	if p.Budget < 0 {
+		return errors.New("retry budget cannot be negative")
+	}
+	return nil
+}
+
+// retryable tells whether err carries one of the retryable statuses of p.
+func (p RetryPolicy) retryable(err error) bool {
+	retryCodes := p.Codes
+	if len(retryCodes) == 0 {
+		retryCodes = DefaultRetryableCodes
+	}
+	return slices.Contains(retryCodes, status.Code(err))
+}
+
+// backoff returns the wait before the given attempt, the second being the
+// first to wait. random returns a number in [0, 1).
+func (p RetryPolicy) backoff(attempt int, random func() float64) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialBackoff)
+	for range attempt - 2 {
+		delay *= multiplier
+		if p.MaxBackoff > 0 && delay >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 {
+		delay = min(delay, float64(p.MaxBackoff))
+	}
+	delay *= 1 + p.Jitter*(2*random()-1)
+	return time.Duration(delay)
+}
+
+// do runs attempt until it succeeds, fails with an error that is not
+// retryable or p runs out of attempts or budget, and returns its last error.
+// attempt reports whether it can be started over after failing.
+func (p RetryPolicy) do(ctx context.Context, operation string, attempt func(ctx context.Context) (resendable bool, err error)) error {
+	start := time.Now()
+	for n := 1; ; n++ {
+		if n > 1 {
+			log.Logger.Infof("Retrying %s [attempt=%d/%d]", operation, n, p.MaxAttempts)
+		}
+		resendable, err := attempt(ctx)
+		if err == nil || n >= p.MaxAttempts || !p.retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if !resendable {
+			log.Logger.Warnf("Not retrying %s, it cannot be started over: %v", operation, err)
+			return err
+		}
+
+		delay := p.backoff(n+1, rand.Float64)
+		if p.Budget > 0 && time.Since(start)+delay > p.Budget {
+			log.Logger.Warnf("Not retrying %s, the retry budget of %s would be exceeded: %v", operation, p.Budget, err)
+			return err
+		}
+		log.Logger.Warnf("Attempt %d of %s failed, retrying in %s: %v", n, operation, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s interrupted while waiting to retry: %w", operation, ctx.Err())
+		}
+	}
+}
+
+// WithRetry makes the client start a recognition or synthesis again when it
+// fails with one of the retryable statuses of policy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *clientOptions) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		o.retry = policy
+		return nil
+	}
+}
+
+// readCounter counts the bytes read through it, telling whether a stream
+// can still be sent again from its start.
+type readCounter struct {
+	reader io.Reader
+	read   int64
+}
+
+func (c *readCounter) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += int64(n)
+	return n, err
+}
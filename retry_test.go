@@ -0,0 +1,161 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	ttsv1 "verbio_speech_center/proto/speechcenter/tts"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rejectingServer fails its first rejections streams with code, either as
+// soon as they open or, with afterAudio, once all of their audio has been
+// read, and answers the others like fakeRecognizerServer.
+func rejectingServer(rejections int, code codes.Code, afterAudio bool) *fakeRecognizerServer {
+	reject := func(n int, _ recognizeStream) error {
+		if n <= rejections {
+			return status.Error(code, "try again later")
+		}
+		return nil
+	}
+	if afterAudio {
+		return &fakeRecognizerServer{onEnd: reject}
+	}
+	return &fakeRecognizerServer{onConfig: reject}
+}
+
+// rejectingSynthesisServer fails its first rejections streams with code.
+func rejectingSynthesisServer(rejections int, code codes.Code) *fakeTextToSpeechServer {
+	return &fakeTextToSpeechServer{onRequest: func(n int) error {
+		if n <= rejections {
+			return status.Error(code, "try again later")
+		}
+		return nil
+	}}
+}
+
+var fastRetries = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+func TestRecogniseRetries(t *testing.T) {
+	server := rejectingServer(2, codes.Unavailable, false)
+	recogniser := newFakeServerRecogniser(t, server, WithRetry(fastRetries))
+	defer recogniser.Close()
+
+	transcript, err := recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Topic: "generic", Language: "en-US"})
+	assert.NoError(t, err)
+	assert.Equal(t, "en-US", transcript.Text())
+	assert.Equal(t, 3, server.streams)
+}
+
+func TestRecogniseRetriesExhausted(t *testing.T) {
+	server := rejectingServer(3, codes.ResourceExhausted, false)
+	recogniser := newFakeServerRecogniser(t, server, WithRetry(fastRetries))
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Topic: "generic"})
+	assert.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 3, server.streams)
+}
+
+func TestRecogniseDoesNotRetryOtherCodes(t *testing.T) {
+	server := rejectingServer(1, codes.PermissionDenied, false)
+	recogniser := newFakeServerRecogniser(t, server, WithRetry(fastRetries))
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Topic: "generic"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, 1, server.streams)
+
+	server = rejectingServer(1, codes.PermissionDenied, false)
+	policy := fastRetries
+	policy.Codes = []codes.Code{codes.PermissionDenied}
+	recogniser = newFakeServerRecogniser(t, server, WithRetry(policy))
+	defer recogniser.Close()
+
+	_, err = recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Topic: "generic"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.streams)
+}
+
+func TestRecogniseRetryBudget(t *testing.T) {
+	server := rejectingServer(1, codes.Unavailable, false)
+	recogniser := newFakeServerRecogniser(t, server, WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, Budget: time.Second}))
+	defer recogniser.Close()
+
+	_, err := recogniser.Recognise(context.Background(), spokenWav(t, 1), RecognitionOptions{Topic: "generic"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, server.streams)
+}
+
+func TestRecogniseDoesNotRetryAfterResults(t *testing.T) {
+	tests := []struct {
+		reconnects int
+		streams    int
+	}{
+		{0, 1},
+		{1, 2},
+	}
+	for _, tt := range tests {
+		server := flakyServer(3, codes.Unavailable)
+		recogniser := newFakeServerRecogniser(t, server, WithReconnect(ReconnectPolicy{MaxReconnects: tt.reconnects}), WithRetry(fastRetries))
+		defer recogniser.Close()
+
+		_, err := recogniser.Recognise(context.Background(), spokenWav(t, 3), RecognitionOptions{Topic: "generic"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, tt.streams, server.streams)
+	}
+}
+
+func TestRecogniseStreamDoesNotRetryReadAudio(t *testing.T) {
+	server := rejectingServer(1, codes.Unavailable, true)
+	recogniser := newFakeServerRecogniser(t, server, WithRetry(fastRetries))
+	defer recogniser.Close()
+
+	_, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 1600)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 8000})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, server.streams)
+}
+
+func TestSynthesizeRetries(t *testing.T) {
+	server := rejectingSynthesisServer(2, codes.Unavailable)
+	synthesizer := newFakeServerSynthesizer(t, server, WithRetry(fastRetries))
+	defer synthesizer.Close()
+
+	output := filepath.Join(t.TempDir(), "out.raw")
+	err := synthesizer.StreamingSynthesizeSpeech(context.Background(), "hello", "voice", ttsv1.VoiceSamplingRate_VOICE_SAMPLING_RATE_16KHZ,
+		ttsv1.AudioFormat_AUDIO_FORMAT_RAW_LPCM_S16LE, output)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, server.streams)
+	audio, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(audio))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	middle := func() float64 { return 0.5 }
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(2, middle))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(3, middle))
+	assert.Equal(t, 800*time.Millisecond, policy.backoff(5, middle))
+	assert.Equal(t, time.Second, policy.backoff(6, middle))
+	assert.Equal(t, time.Second, policy.backoff(60, middle))
+
+	policy.Multiplier = 3
+	assert.Equal(t, 900*time.Millisecond, policy.backoff(4, middle))
+
+	policy.Jitter = 0.5
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(2, func() float64 { return 0 }))
+	assert.Equal(t, 150*time.Millisecond, policy.backoff(2, func() float64 { return 1 }))
+
+	for _, invalid := range []RetryPolicy{{MaxAttempts: -1}, {Jitter: 1.5}, {Multiplier: 0.5}, {Budget: -time.Second}} {
+		_, err := newClientOptions([]Option{WithRetry(invalid)})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,281 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"verbio_speech_center/log"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+)
+
+// energyFrame is the length of audio over which the energy is measured when
+// looking for a silence to cut at.
+const energyFrame = 10 * time.Millisecond
+
+// SegmentationOptions configures the recognition of long audio as several
+// overlapping segments recognised at the same time.
+type SegmentationOptions struct {
+	// SegmentDuration is the longest a segment may be, overlap aside. Each cut
+	// is made at the quietest point of the last fifth of the segment.
+	SegmentDuration time.Duration
+	// Overlap is the audio around each cut that both neighbouring segments
+	// recognise, so words at the cut are heard whole by at least one of them.
+	Overlap time.Duration
+	// Workers is the number of segments recognised at the same time.
+	Workers int
+}
+
+// DefaultSegmentation cuts audio into five-minute segments, well below the
+// length of a recognition session, recognising four at a time.
+var DefaultSegmentation = SegmentationOptions{
+	SegmentDuration: 5 * time.Minute,
+	Overlap:         2 * time.Second,
+	Workers:         4,
+}
+
+// Validate reports a problem with s.
+func (s SegmentationOptions) Validate() error {
+	if s.SegmentDuration <= 0 {
+		return errors.New("segment duration must be positive")
+	}
+	if s.Overlap < 0 {
+		return errors.New("segment overlap cannot be negative")
+	}
+	if s.Overlap >= s.SegmentDuration {
+		return fmt.Errorf("segment overlap %s must be shorter than the segment duration %s", s.Overlap, s.SegmentDuration)
+	}
+	return nil
+}
+
+// audioSegment is a part of the audio recognised on its own stream.
+type audioSegment struct {
+	// start and end delimit the audio sent, overlap included.
+	start, end time.Duration
+	// keepFrom and keepUntil delimit the words of the segment that make it
+	// into the stitched transcript: those between the cuts on either side.
+	keepFrom, keepUntil time.Duration
+}
+
+// RecogniseLong recognises audioFile in overlapping segments cut at silences,
+// several of them at the same time, and stitches their transcripts into one
+// whose timings are relative to the start of the file.
+func (r *Recogniser) RecogniseLong(ctx context.Context, audioFile string, opts RecognitionOptions, segmentation SegmentationOptions) (*Transcript, error) {
+	log.Logger.Infof("Performing segmented recognition [audioFile=%s] [grammar=%s] [topic=%s] [language=%s] [segment=%s] [overlap=%s] [workers=%d]",
+		audioFile, opts.Grammar, opts.Topic, opts.Language, segmentation.SegmentDuration, segmentation.Overlap, segmentation.Workers)
+	if err := opts.Validate(); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, fmt.Errorf("invalid recognition options: %w", err))
+	}
+	if err := segmentation.Validate(); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, fmt.Errorf("invalid segmentation options: %w", err))
+	}
+	if opts.EndOfUtteranceSilence > 0 {
+		return nil, recognitionError(ErrInvalidOptions, errors.New("end-of-utterance detection is not supported in segmented recognition"))
+	}
+
+	audio, err := loadAudio(audioFile, r.options.targetSampleRate)
+	if err != nil {
+		return nil, audioLoadError(err)
+	}
+	if err := opts.checkChannels(audio.channels); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, err)
+	}
+	configuration, err := opts.request(r.options.grammarType, audio.sampleRate, audio.channels)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := planSegments(audio, segmentation)
+	log.Logger.Infof("Recognising %s of audio in %d segments", audio.duration(), len(segments))
+	transcripts, err := r.recogniseSegments(ctx, audio, segments, configuration, max(segmentation.Workers, 1))
+	if err != nil {
+		return nil, err
+	}
+	return stitchTranscripts(segments, transcripts), nil
+}
+
+// recogniseSegments recognises every segment with up to workers streams at a
+// time. The first segment to fail cancels the others.
+func (r *Recogniser) recogniseSegments(ctx context.Context, audio *audioData, segments []audioSegment, configuration *sttv1.RecognitionStreamingRequest, workers int) ([]*Transcript, error) {
+	return recogniseAll(ctx, len(segments), workers, "segment", func(ctx context.Context, i int) (*Transcript, error) {
+		segment := segments[i]
+		log.Logger.Infof("Starting recognition of segment %d [start=%s] [end=%s]", i, segment.start, segment.end)
+		return r.performRecognition(ctx, audio.slice(segment.start, segment.end), configuration, 0)
+	})
+}
+
+// planSegments cuts audio at the quietest frame of the last fifth of every
+// segment and widens each segment by half the overlap on either side.
+func planSegments(audio *audioData, segmentation SegmentationOptions) []audioSegment {
+	total := audio.duration()
+	energies := audio.frameEnergies()
+	search := segmentation.SegmentDuration / 5
+
+	cuts := []time.Duration{0}
+	for last := cuts[0]; total-last > segmentation.SegmentDuration; last = cuts[len(cuts)-1] {
+		from, to := last+segmentation.SegmentDuration-search, last+segmentation.SegmentDuration
+		cut := to
+		quietest := -1.0
+		for frame := int(from / energyFrame); frame < int(to/energyFrame) && frame < len(energies); frame++ {
+			if quietest < 0 || energies[frame] < quietest {
+				quietest = energies[frame]
+				cut = time.Duration(frame) * energyFrame
+			}
+		}
+		cuts = append(cuts, cut)
+	}
+	cuts = append(cuts, total)
+
+	half := segmentation.Overlap / 2
+	segments := make([]audioSegment, len(cuts)-1)
+	for i := range segments {
+		segments[i] = audioSegment{
+			start:     max(cuts[i]-half, 0),
+			end:       min(cuts[i+1]+half, total),
+			keepFrom:  cuts[i],
+			keepUntil: cuts[i+1],
+		}
+	}
+	return segments
+}
+
+// stitchTranscripts shifts the timings of every segment transcript by the
+// offset of its segment and keeps only the words centred between the cuts
+// around the segment, so the words heard in an overlap appear once. A word
+// repeated on both sides of a cut is dropped from the second segment.
+func stitchTranscripts(segments []audioSegment, transcripts []*Transcript) *Transcript {
+	stitched := &Transcript{Segments: make([]Segment, 0)}
+	var previous Word
+	for i, transcript := range transcripts {
+		segment := segments[i]
+		keep := func(start, end time.Duration) bool {
+			middle := segment.start + (start+end)/2
+			return middle >= segment.keepFrom && (middle < segment.keepUntil || i == len(segments)-1)
+		}
+
+		for _, result := range transcript.Segments {
+			if len(result.Words) == 0 {
+				if result.Text != "" && keep(result.Start, result.End) {
+					stitched.Segments = append(stitched.Segments, result.shift(segment.start))
+				}
+				continue
+			}
+
+			words := make([]Word, 0, len(result.Words))
+			for _, word := range result.Words {
+				if !keep(word.Start, word.End) {
+					continue
+				}
+				word = word.shift(segment.start)
+				if isRepeatedWord(previous, word) {
+					continue
+				}
+				words = append(words, word)
+				previous = word
+			}
+			if len(words) == 0 {
+				continue
+			}
+
+			shifted := result.shift(segment.start)
+			if len(words) < len(result.Words) {
+				shifted.Words = words
+				shifted.Text = joinWords(words)
+				shifted.Start = min(shifted.Start, words[0].Start)
+				shifted.End = max(shifted.End, words[len(words)-1].End)
+				shifted.Alternatives = []Alternative{{Text: shifted.Text, Confidence: shifted.Confidence, Words: words}}
+			}
+			stitched.Segments = append(stitched.Segments, shifted)
+		}
+	}
+	return stitched
+}
+
+// isRepeatedWord tells whether next is the same word as previous, heard again
+// by the following segment at an overlapping time.
+func isRepeatedWord(previous, next Word) bool {
+	return previous.Text != "" && strings.EqualFold(previous.Text, next.Text) && next.Start < previous.End
+}
+
+func joinWords(words []Word) string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Text
+	}
+	return strings.Join(texts, " ")
+}
+
+// shift moves the segment and all of its words offset later.
+func (s Segment) shift(offset time.Duration) Segment {
+	s.Start += offset
+	s.End += offset
+	s.Words = shiftWords(s.Words, offset)
+	alternatives := make([]Alternative, len(s.Alternatives))
+	for i, alternative := range s.Alternatives {
+		alternative.Words = shiftWords(alternative.Words, offset)
+		alternatives[i] = alternative
+	}
+	s.Alternatives = alternatives
+	return s
+}
+
+func (w Word) shift(offset time.Duration) Word {
+	w.Start += offset
+	w.End += offset
+	return w
+}
+
+func shiftWords(words []Word, offset time.Duration) []Word {
+	shifted := make([]Word, len(words))
+	for i, word := range words {
+		shifted[i] = word.shift(offset)
+	}
+	return shifted
+}
+
+// duration returns the length of the audio.
+func (a *audioData) duration() time.Duration {
+	frames := len(a.samples) / a.frameSize()
+	return time.Duration(frames) * time.Second / time.Duration(a.sampleRate)
+}
+
+func (a *audioData) frameSize() int {
+	return bytesPerSample * int(max(a.channels, 1))
+}
+
+// offset returns the byte offset of the frame at position.
+func (a *audioData) offset(position time.Duration) int {
+	frame := int(position * time.Duration(a.sampleRate) / time.Second)
+	return min(frame*a.frameSize(), len(a.samples))
+}
+
+// slice returns the audio between start and end.
+func (a *audioData) slice(start, end time.Duration) *audioData {
+	return &audioData{
+		samples:    a.samples[a.offset(start):a.offset(end)],
+		sampleRate: a.sampleRate,
+		channels:   a.channels,
+	}
+}
+
+// frameEnergies returns the mean square amplitude of every energyFrame of
+// the audio, all channels together.
+func (a *audioData) frameEnergies() []float64 {
+	size := int(energyFrame*time.Duration(a.sampleRate)/time.Second) * a.frameSize()
+	if size == 0 {
+		return nil
+	}
+	energies := make([]float64, 0, len(a.samples)/size+1)
+	for start := 0; start < len(a.samples); start += size {
+		frame := a.samples[start:min(start+size, len(a.samples))]
+		var sum float64
+		for i := 0; i+1 < len(frame); i += bytesPerSample {
+			sample := float64(int16(binary.LittleEndian.Uint16(frame[i:])))
+			sum += sample * sample
+		}
+		energies = append(energies, sum/float64(len(frame)/bytesPerSample))
+	}
+	return energies
+}
@@ -0,0 +1,129 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// spokenAudio returns mono LPCM at 8 kHz holding one word a second: word k
+// is heard from 0.2 s to 0.8 s into second k-1 as samples of value k, and the
+// rest is silence.
+func spokenAudio(seconds int) *audioData {
+	const rate = 8000
+	samples := make([]byte, seconds*rate*bytesPerSample)
+	for second := 0; second < seconds; second++ {
+		for i := second*rate + rate/5; i < second*rate+rate*4/5; i++ {
+			binary.LittleEndian.PutUint16(samples[i*bytesPerSample:], uint16(second+1))
+		}
+	}
+	return &audioData{samples: samples, sampleRate: rate, channels: 1}
+}
+
+// transcribeRuns hears every run of equal non-zero samples as a word named
+// after the sample value.
+func transcribeRuns(sampleRate uint32, audio []byte) *sttv1.RecognitionResult {
+	at := func(i int) float32 { return float32(i) / float32(sampleRate) }
+	var words []*sttv1.WordInfo
+	var texts []string
+	start, value := 0, uint16(0)
+	for i := 0; i <= len(audio)/bytesPerSample; i++ {
+		sample := uint16(0)
+		if i < len(audio)/bytesPerSample {
+			sample = binary.LittleEndian.Uint16(audio[i*bytesPerSample:])
+		}
+		if sample == value {
+			continue
+		}
+		if value != 0 {
+			text := fmt.Sprintf("w%d", value)
+			words = append(words, word(text, at(start), at(i)))
+			texts = append(texts, text)
+		}
+		start, value = i, sample
+	}
+	return finalResult(strings.Join(texts, " "), at(len(audio)/bytesPerSample), words...)
+}
+
+func TestPlanSegments(t *testing.T) {
+	segments := planSegments(spokenAudio(12), SegmentationOptions{SegmentDuration: 4 * time.Second, Overlap: time.Second})
+
+	assert.Equal(t, []audioSegment{
+		{start: 0, end: 4300 * time.Millisecond, keepFrom: 0, keepUntil: 3800 * time.Millisecond},
+		{start: 3300 * time.Millisecond, end: 7500 * time.Millisecond, keepFrom: 3800 * time.Millisecond, keepUntil: 7 * time.Second},
+		{start: 6500 * time.Millisecond, end: 11300 * time.Millisecond, keepFrom: 7 * time.Second, keepUntil: 10800 * time.Millisecond},
+		{start: 10300 * time.Millisecond, end: 12 * time.Second, keepFrom: 10800 * time.Millisecond, keepUntil: 12 * time.Second},
+	}, segments)
+
+	single := planSegments(spokenAudio(3), SegmentationOptions{SegmentDuration: 4 * time.Second})
+	assert.Equal(t, []audioSegment{{start: 0, end: 3 * time.Second, keepFrom: 0, keepUntil: 3 * time.Second}}, single)
+}
+
+func TestStitchTranscripts(t *testing.T) {
+	segments := []audioSegment{
+		{start: 0, end: 6 * time.Second, keepFrom: 0, keepUntil: 5 * time.Second},
+		{start: 4 * time.Second, end: 9 * time.Second, keepFrom: 5 * time.Second, keepUntil: 9 * time.Second},
+	}
+	transcripts := []*Transcript{
+		newTranscript([]*sttv1.RecognitionResult{
+			finalResult("hello there", 4, word("hello", 1, 1.5), word("there", 2, 2.5)),
+			finalResult("general kenobi", 2, word("general", 4.6, 4.98), word("kenobi", 5.2, 5.8)),
+		}),
+		newTranscript([]*sttv1.RecognitionResult{
+			// The second segment hears "general" again, centred after the cut.
+			finalResult("general kenobi you are", 4, word("general", 0.9, 1.2), word("kenobi", 1.2, 1.8), word("you", 2, 2.2), word("are", 2.3, 2.6)),
+		}),
+	}
+
+	stitched := stitchTranscripts(segments, transcripts)
+	assert.Equal(t, "hello there general kenobi you are", stitched.Text())
+	words := stitched.Words()
+	assert.Len(t, words, 6)
+	assert.InDelta(t, float64(4600*time.Millisecond), float64(words[2].Start), float64(time.Microsecond))
+	assert.InDelta(t, float64(5200*time.Millisecond), float64(words[3].Start), float64(time.Microsecond))
+	assert.InDelta(t, float64(6300*time.Millisecond), float64(words[5].Start), float64(time.Microsecond))
+	assert.Equal(t, 4*time.Second, stitched.Segments[2].Start)
+	assert.Equal(t, 8*time.Second, stitched.Segments[2].End)
+}
+
+func TestRecogniseLong(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{transcribe: transcribeRuns})
+	defer recogniser.Close()
+
+	audio := spokenAudio(12)
+	samples := make([]int, len(audio.samples)/bytesPerSample)
+	for i := range samples {
+		samples[i] = int(int16(binary.LittleEndian.Uint16(audio.samples[i*bytesPerSample:])))
+	}
+	file := createTemporaryWav(t, 8000, 16, 1, samples)
+
+	segmentation := SegmentationOptions{SegmentDuration: 4 * time.Second, Overlap: time.Second, Workers: 2}
+	transcript, err := recogniser.RecogniseLong(context.Background(), file, RecognitionOptions{Topic: "generic"}, segmentation)
+	assert.NoError(t, err)
+
+	words := transcript.Words()
+	assert.Len(t, words, 12)
+	for i, word := range words {
+		assert.Equal(t, fmt.Sprintf("w%d", i+1), word.Text)
+		assert.InDelta(t, float64(time.Duration(i)*time.Second+200*time.Millisecond), float64(word.Start), float64(time.Millisecond))
+		assert.InDelta(t, float64(time.Duration(i)*time.Second+800*time.Millisecond), float64(word.End), float64(time.Millisecond))
+	}
+}
+
+func TestRecogniseLongInvalidOptions(t *testing.T) {
+	recogniser := newFakeRecogniser(newFakeRecognitionStream(), DefaultPacing)
+
+	_, err := recogniser.RecogniseLong(context.Background(), "missing.wav", RecognitionOptions{Topic: "generic"}, SegmentationOptions{SegmentDuration: time.Second, Overlap: time.Second})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be shorter than the segment duration")
+
+	_, err = recogniser.RecogniseLong(context.Background(), "missing.wav", RecognitionOptions{Topic: "generic", EndOfUtteranceSilence: time.Second}, DefaultSegmentation)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported in segmented recognition")
+}
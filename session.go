@@ -0,0 +1,148 @@
+package verbio_speech_center
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+	"verbio_speech_center/log"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+	ttsv1 "verbio_speech_center/proto/speechcenter/tts"
+
+	"google.golang.org/grpc"
+)
+
+// recognitionSession holds the state of a single recognition stream. Every
+// call creates its own session, so that one Recogniser can serve concurrent
+// requests over its connection.
+type recognitionSession struct {
+	stream  grpc.BidiStreamingClient[sttv1.RecognitionStreamingRequest, sttv1.RecognitionStreamingResponse]
+	pacer   *pacer
+	handler ResultHandler
+	// endOfUtterance is the trailing silence that stops the sending of audio.
+	// stop is closed once it is reached, and is nil when it is not enabled.
+	endOfUtterance time.Duration
+	stop           chan struct{}
+	stopOnce       sync.Once
+	// stoppedAt is the amount of audio sent before stopping.
+	stoppedAt time.Duration
+}
+
+// synthesisSession holds the state of a single synthesis stream.
+type synthesisSession struct {
+	stream grpc.BidiStreamingClient[ttsv1.StreamingSynthesisRequest, ttsv1.StreamingSynthesisResponse]
+}
+
+// duplex coordinates the sending and the receiving half of a stream opened
+// with its context: the first half to fail cancels the stream, so that the
+// other one returns too, and its error is the one reported.
+type duplex struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	// err is the first failure, to be read once both halves have returned.
+	err error
+}
+
+func newDuplex(ctx context.Context) *duplex {
+	ctx, cancel := context.WithCancel(ctx)
+	return &duplex{ctx: ctx, cancel: cancel}
+}
+
+// fail records err unless the other half failed first, and cancels the
+// stream.
+func (d *duplex) fail(err error) {
+	d.once.Do(func() {
+		d.err = err
+		d.cancel()
+	})
+}
+
+// sent reports the outcome of the sending half and returns err, unless it is
+// io.EOF: the server ended the stream and its status is reported to the
+// receiving half. Any other error fails the stream.
+func (d *duplex) sent(err error) error {
+	if errors.Is(err, io.EOF) {
+		log.Logger.Debugf("Stream closed by the server while sending: %v", err)
+		return nil
+	}
+	if err != nil {
+		d.fail(err)
+	}
+	return err
+}
+
+// sessionTracker keeps count of the sessions running over a connection, so
+// that closing the client can cancel them and wait for them to return. The
+// zero value is ready to use.
+type sessionTracker struct {
+	mu       sync.Mutex
+	closing  bool
+	running  int
+	inFlight sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func (t *sessionTracker) init() {
+	if t.ctx == nil {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// begin registers a new session. The returned context is cancelled when
+// either ctx is done or the client is closed, and release must be called
+// once the session has finished.
+func (t *sessionTracker) begin(ctx context.Context) (context.Context, func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return nil, nil, ErrClientClosed
+	}
+	t.init()
+	t.running++
+	t.inFlight.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(t.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		t.mu.Lock()
+		t.running--
+		t.mu.Unlock()
+		t.inFlight.Done()
+	}, nil
+}
+
+// shutdown stops accepting new sessions and waits for the running ones to
+// finish. If ctx is done first, the remaining sessions are cancelled and
+// waited for, and the context error is returned.
+func (t *sessionTracker) shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closing = true
+	t.init()
+	idle := t.running == 0
+	t.mu.Unlock()
+	if idle {
+		t.cancel()
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.cancel()
+		return nil
+	case <-ctx.Done():
+		t.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,198 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	ttsv1 "verbio_speech_center/proto/speechcenter/tts"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const concurrentSessions = 20
+
+func TestConcurrentRecognitions(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{})
+	defer recogniser.Close()
+
+	var wg sync.WaitGroup
+	for i := range concurrentSessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			language := fmt.Sprintf("lang-%d", i)
+			res, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 8000+i*100)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic", Language: language}, SampleRate: 8000})
+			assert.NoError(t, err)
+			assert.Equal(t, language, res)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentChannelRecognitions(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{})
+	defer recogniser.Close()
+	audioFile := createTemporaryWav(t, 8000, 16, 2, make([]int, 1600))
+
+	var wg sync.WaitGroup
+	for i := range concurrentSessions / 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			language := fmt.Sprintf("lang-%d", i)
+			res, err := recogniser.RecogniseChannelsWithTopic(context.Background(), audioFile, "generic", language, nil)
+			assert.NoError(t, err)
+			if assert.NotNil(t, res) {
+				assert.Equal(t, []string{language, language}, res.Channels)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentSyntheses(t *testing.T) {
+	synthesizer := newFakeServerSynthesizer(t, &fakeTextToSpeechServer{})
+	defer synthesizer.Close()
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for i := range concurrentSessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text := fmt.Sprintf("text number %d", i)
+			output := filepath.Join(dir, fmt.Sprintf("%d.raw", i))
+			err := synthesizer.StreamingSynthesizeSpeech(context.Background(), text, "voice",
+				ttsv1.VoiceSamplingRate_VOICE_SAMPLING_RATE_16KHZ, ttsv1.AudioFormat_AUDIO_FORMAT_RAW_LPCM_S16LE, output)
+			assert.NoError(t, err)
+			audio, err := os.ReadFile(output)
+			assert.NoError(t, err)
+			assert.Equal(t, text, string(audio))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSessionTrackerClosed(t *testing.T) {
+	var tracker sessionTracker
+	ctx, release, err := tracker.begin(context.Background())
+	assert.NoError(t, err)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan error)
+	go func() {
+		done <- tracker.shutdown(cancelled)
+	}()
+
+	<-ctx.Done()
+	release()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+
+	_, _, err = tracker.begin(context.Background())
+	assert.True(t, errors.Is(err, ErrClientClosed))
+}
+
+// midStreamFailingServer fails every recognition stream with code once it
+// has received the first chunk of audio.
+func midStreamFailingServer(code codes.Code) *fakeRecognizerServer {
+	return &fakeRecognizerServer{onAudio: func(int, recognizeStream, uint32, []byte) error {
+		return status.Error(code, "stream failed")
+	}}
+}
+
+// midStreamFailingSynthesisServer fails every synthesis stream with code once
+// it has received the configuration.
+func midStreamFailingSynthesisServer(code codes.Code) *fakeTextToSpeechServer {
+	return &fakeTextToSpeechServer{onRequest: func(int) error {
+		return status.Error(code, "stream failed")
+	}}
+}
+
+// failingReader returns err once its data has been read.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, f.err
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+// assertNoStreamGoroutines fails the test if a goroutine sending to or
+// receiving from a stream outlives it, giving them a moment to exit.
+func assertNoStreamGoroutines(t *testing.T) {
+	t.Helper()
+	var stacks string
+	for range 100 {
+		buffer := make([]byte, 1<<20)
+		stacks = string(buffer[:runtime.Stack(buffer, true)])
+		if !strings.Contains(stacks, "collectResponses") && !strings.Contains(stacks, "collectAudioChunks") &&
+			!strings.Contains(stacks, "performStreamRecognition") && !strings.Contains(stacks, "performSynthesis") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("stream goroutines still running:\n%s", stacks)
+}
+
+func TestRecognitionServerErrorStopsSending(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, midStreamFailingServer(codes.Internal), WithPacing(DefaultPacing))
+	defer recogniser.Close()
+
+	// A minute of audio streamed in real time.
+	start := time.Now()
+	_, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 60*16000)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 8000})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.False(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assertNoStreamGoroutines(t)
+}
+
+func TestRecognitionSendErrorStopsReceiving(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{hang: true})
+	defer recogniser.Close()
+
+	audio := &failingReader{data: make([]byte, 3200), err: errors.New("disk on fire")}
+	_, err := recogniser.RecogniseStream(context.Background(), audio, StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 8000})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "disk on fire")
+	assert.False(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	assertNoStreamGoroutines(t)
+}
+
+func TestRecognitionCancelledStreamExits(t *testing.T) {
+	recogniser := newFakeServerRecogniser(t, &fakeRecognizerServer{hang: true})
+	defer recogniser.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := recogniser.RecogniseStream(ctx, bytes.NewReader(make([]byte, 1600)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 8000})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+	assertNoStreamGoroutines(t)
+}
+
+func TestSynthesisServerErrorExits(t *testing.T) {
+	synthesizer := newFakeServerSynthesizer(t, midStreamFailingSynthesisServer(codes.Internal))
+	defer synthesizer.Close()
+
+	err := synthesizer.StreamingSynthesizeSpeech(context.Background(), "hello", "voice", ttsv1.VoiceSamplingRate_VOICE_SAMPLING_RATE_16KHZ,
+		ttsv1.AudioFormat_AUDIO_FORMAT_WAV_LPCM_S16LE, filepath.Join(t.TempDir(), "out.wav"))
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assertNoStreamGoroutines(t)
+}
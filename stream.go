@@ -0,0 +1,205 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"verbio_speech_center/log"
+)
+
+// wavStreamingSize is the chunk size written by encoders that do not know
+// the final length of the audio, e.g. when writing to a pipe.
+const wavStreamingSize = 0xFFFFFFFF
+
+// StreamConfig describes a recognition performed on a stream of audio.
+type StreamConfig struct {
+	RecognitionOptions
+	// SampleRate describes headerless input, whose channels are given by
+	// RecognitionOptions.Channels. It is ignored when the stream starts with
+	// a WAV header.
+	SampleRate uint32
+	// Encoding is the encoding of headerless input, 16-bit LPCM when empty.
+	// When the stream starts with a WAV header it must agree with it.
+	Encoding AudioEncoding
+	// OnResult, when set, receives every interim and final result as it arrives.
+	OnResult ResultHandler
+}
+
+// RecogniseStream recognises 16-bit LPCM or G.711 audio read from audio, either
+// raw or wrapped in a WAV container, or FLAC audio. G.711 and FLAC are decoded
+// to LPCM before sending. Audio is read and sent in chunks as it arrives,
+// so the stream never needs to be held in memory. A failed recognition is only
+// retried if none of the audio had been read yet.
+func (r *Recogniser) RecogniseStream(ctx context.Context, audio io.Reader, config StreamConfig) (string, error) {
+	transcript, err := r.RecogniseStreamTranscript(ctx, audio, config)
+	if err != nil {
+		return "", err
+	}
+	return transcript.Text(), nil
+}
+
+// RecogniseStreamTranscript works like RecogniseStream but keeps the timings,
+// confidences and alternatives of every result.
+func (r *Recogniser) RecogniseStreamTranscript(ctx context.Context, audio io.Reader, config StreamConfig) (*Transcript, error) {
+	log.Logger.Infof("Performing stream recognition [grammar=%s] [topic=%s] [language=%s] [wordBoosting=%v]", config.Grammar, config.Topic, config.Language, config.WordBoosting)
+	if err := config.Validate(); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, fmt.Errorf("invalid recognition options: %w", err))
+	}
+
+	reader := bufio.NewReader(audio)
+	samples, sampleRate, channels, err := openAudioStream(reader, config)
+	if err != nil {
+		return nil, recognitionError(ErrInvalidAudio, fmt.Errorf("error opening audio stream: %w", err))
+	}
+
+	if err := config.checkChannels(channels); err != nil {
+		return nil, recognitionError(ErrInvalidOptions, err)
+	}
+
+	configuration, err := config.request(r.options.grammarType, sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &readCounter{reader: samples}
+	var transcript *Transcript
+	err = r.options.retry.do(ctx, "stream recognition", func(ctx context.Context) (bool, error) {
+		transcript, err = r.performStreamRecognition(ctx, counter, configuration, config.OnResult, config.EndOfUtteranceSilence)
+		return counter.read == 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transcript, nil
+}
+
+// openAudioStream parses the WAV header or FLAC stream at the start of reader,
+// if any, and returns a reader of LPCM samples.
+func openAudioStream(reader *bufio.Reader, config StreamConfig) (io.Reader, uint32, uint16, error) {
+	magic, err := reader.Peek(12)
+	if err != nil && err != io.EOF {
+		return nil, 0, 0, err
+	}
+	if len(magic) == 12 && (bytes.Equal(magic[0:4], flacSignature) || bytes.Equal(magic[0:4], oggSignature)) {
+		if config.Encoding != "" && config.Encoding != EncodingPCM {
+			return nil, 0, 0, fmt.Errorf("FLAC audio cannot be decoded as %s", config.Encoding)
+		}
+		return openFlacStream(reader, bytes.Equal(magic[0:4], oggSignature))
+	}
+	if len(magic) == 12 && bytes.Equal(magic[0:4], []byte("RIFF")) && bytes.Equal(magic[8:12], []byte("WAVE")) {
+		samples, encoding, sampleRate, channels, err := readWavStreamHeader(reader)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if config.Encoding != "" && config.Encoding != encoding {
+			return nil, 0, 0, fmt.Errorf("WAV header declares %s audio but %s was requested", encoding, config.Encoding)
+		}
+		return decodeStream(samples, encoding), sampleRate, channels, nil
+	}
+
+	if config.SampleRate == 0 {
+		return nil, 0, 0, errors.New("raw audio stream needs a sample rate")
+	}
+	if config.Encoding != "" {
+		if _, err := ParseAudioEncoding(string(config.Encoding)); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	channels := config.Channels
+	if channels == 0 {
+		channels = 1
+	}
+	return decodeStream(reader, config.Encoding), config.SampleRate, channels, nil
+}
+
+// openFlacStream decodes FLAC frames as they are read. Ogg FLAC is demuxed in
+// full before decoding starts.
+func openFlacStream(reader io.Reader, ogg bool) (io.Reader, uint32, uint16, error) {
+	if ogg {
+		native, err := demuxOggFlac(reader)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		reader = bytes.NewReader(native)
+	}
+	samples, err := newFlacReader(reader)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	log.Logger.Debugf("Streaming FLAC audio [sampleRate=%d] [channels=%d]", samples.sampleRate(), samples.channels())
+	return samples, samples.sampleRate(), samples.channels(), nil
+}
+
+// decodeStream wraps G.711 audio in a decoder so LPCM is read from it.
+func decodeStream(reader io.Reader, encoding AudioEncoding) io.Reader {
+	if table := encoding.table(); table != nil {
+		log.Logger.Debugf("Decoding %s audio to LPCM", encoding)
+		return newG711Reader(reader, table)
+	}
+	return reader
+}
+
+// readWavStreamHeader reads the RIFF chunks preceding the data chunk without
+// seeking, so it works on pipes and sockets. The returned reader yields the
+// undecoded samples of the data chunk.
+func readWavStreamHeader(reader io.Reader) (io.Reader, AudioEncoding, uint32, uint16, error) {
+	chunk, err := readWavFmtChunk(reader)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+	formatTag, err := wavFormatTag(chunk)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+	channels := binary.LittleEndian.Uint16(chunk[2:])
+	sampleRate := binary.LittleEndian.Uint32(chunk[4:])
+	bitDepth := binary.LittleEndian.Uint16(chunk[14:])
+
+	encoding, ok := wavEncoding(formatTag)
+	if !ok {
+		return nil, "", 0, 0, fmt.Errorf("unsupported WAV audio format %d (only LPCM and G.711 are supported)", formatTag)
+	}
+	if encoding == EncodingPCM && bitDepth != pcmBitDepth {
+		return nil, "", 0, 0, fmt.Errorf("unsupported WAV bit depth %d (only %d-bit LPCM is supported)", bitDepth, pcmBitDepth)
+	}
+	if encoding != EncodingPCM && bitDepth != g711BitDepth {
+		return nil, "", 0, 0, fmt.Errorf("unsupported WAV bit depth %d (%s audio must be %d-bit)", bitDepth, encoding, g711BitDepth)
+	}
+	if sampleRate == 0 || channels == 0 {
+		return nil, "", 0, 0, errors.New("invalid WAV fmt chunk")
+	}
+
+	for {
+		var header struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+			return nil, "", 0, 0, fmt.Errorf("error reading WAV chunk header: %w", err)
+		}
+		if string(header.ID[:]) != "data" {
+			if err := skipChunk(reader, header.Size); err != nil {
+				return nil, "", 0, 0, err
+			}
+			continue
+		}
+
+		log.Logger.Debugf("Streaming WAV audio [encoding=%s] [sampleRate=%d] [channels=%d]", encoding, sampleRate, channels)
+		if header.Size == 0 || header.Size == wavStreamingSize {
+			return reader, encoding, sampleRate, channels, nil
+		}
+		return io.LimitReader(reader, int64(header.Size)), encoding, sampleRate, channels, nil
+	}
+}
+
+// skipChunk discards the remaining size bytes of a chunk and its padding byte.
+func skipChunk(reader io.Reader, size uint32) error {
+	if _, err := io.CopyN(io.Discard, reader, int64(size)+int64(size%2)); err != nil {
+		return fmt.Errorf("error skipping WAV chunk: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeRecognitionStream records the requests it receives and answers with
+// the given responses once the client closes its sending side.
+type fakeRecognitionStream struct {
+	grpc.ClientStream
+	ctx       context.Context
+	mu        sync.Mutex
+	requests  []*sttv1.RecognitionStreamingRequest
+	responses []*sttv1.RecognitionStreamingResponse
+	closed    chan struct{}
+	// live responses are answered once the first audio chunk arrives, while
+	// the client is still sending.
+	live         []*sttv1.RecognitionStreamingResponse
+	audioStarted chan struct{}
+	startOnce    sync.Once
+	// hang keeps Recv blocked after CloseSend, as a server that never answers.
+	hang bool
+}
+
+func newFakeRecognitionStream(responses ...*sttv1.RecognitionStreamingResponse) *fakeRecognitionStream {
+	return &fakeRecognitionStream{responses: responses, closed: make(chan struct{}), audioStarted: make(chan struct{})}
+}
+
+func (f *fakeRecognitionStream) Send(request *sttv1.RecognitionStreamingRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request)
+	if request.GetAudio() != nil {
+		f.startOnce.Do(func() { close(f.audioStarted) })
+	}
+	return nil
+}
+
+func (f *fakeRecognitionStream) Recv() (*sttv1.RecognitionStreamingResponse, error) {
+	if response := f.nextLive(); response != nil {
+		return response, nil
+	}
+	select {
+	case <-f.closed:
+	case <-f.ctx.Done():
+		return nil, status.FromContextError(f.ctx.Err()).Err()
+	}
+	if f.hang {
+		<-f.ctx.Done()
+		return nil, status.FromContextError(f.ctx.Err()).Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.responses) == 0 {
+		return nil, io.EOF
+	}
+	response := f.responses[0]
+	f.responses = f.responses[1:]
+	return response, nil
+}
+
+func (f *fakeRecognitionStream) nextLive() *sttv1.RecognitionStreamingResponse {
+	f.mu.Lock()
+	pending := len(f.live) > 0
+	f.mu.Unlock()
+	if !pending {
+		return nil
+	}
+	select {
+	case <-f.audioStarted:
+	case <-f.ctx.Done():
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := f.live[0]
+	f.live = f.live[1:]
+	return response
+}
+
+func (f *fakeRecognitionStream) RecvMsg(m any) error {
+	response, err := f.Recv()
+	if err != nil {
+		return err
+	}
+	proto.Merge(m.(proto.Message), response)
+	return nil
+}
+
+func (f *fakeRecognitionStream) CloseSend() error {
+	close(f.closed)
+	return nil
+}
+
+func (f *fakeRecognitionStream) Header() (metadata.MD, error) { return nil, nil }
+func (f *fakeRecognitionStream) Trailer() metadata.MD         { return nil }
+func (f *fakeRecognitionStream) Context() context.Context     { return f.ctx }
+
+type fakeRecognizerClient struct {
+	stream *fakeRecognitionStream
+}
+
+func (f *fakeRecognizerClient) StreamingRecognize(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[sttv1.RecognitionStreamingRequest, sttv1.RecognitionStreamingResponse], error) {
+	f.stream.ctx = ctx
+	return f.stream, nil
+}
+
+func newFakeRecogniser(stream *fakeRecognitionStream, pacing Pacing) *Recogniser {
+	return &Recogniser{
+		client:  &fakeRecognizerClient{stream: stream},
+		options: clientOptions{pacing: pacing},
+	}
+}
+
+func resultResponse(result *sttv1.RecognitionResult) *sttv1.RecognitionStreamingResponse {
+	return &sttv1.RecognitionStreamingResponse{
+		RecognitionResponse: &sttv1.RecognitionStreamingResponse_Result{Result: result},
+	}
+}
+
+func TestRecogniseStream(t *testing.T) {
+	stream := newFakeRecognitionStream(
+		resultResponse(finalResult("hello", 1)),
+		resultResponse(finalResult("world", 1)),
+	)
+	recogniser := newFakeRecogniser(stream, DefaultPacing)
+
+	audio, err := os.ReadFile(createTemporaryWav(t, 16000, 16, 1, make([]int, 1000)))
+	assert.NoError(t, err)
+
+	res, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(audio), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic", Language: "en-US"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", res)
+
+	assert.Len(t, stream.requests, 4)
+	assert.Equal(t, uint32(16000), stream.requests[0].GetConfig().GetParameters().GetPcm().GetSampleRateHz())
+	assert.Len(t, stream.requests[1].GetAudio(), 1600)
+	assert.Len(t, stream.requests[2].GetAudio(), 400)
+	assert.Equal(t, sttv1.EventMessage_END_OF_STREAM, stream.requests[3].GetEventMessage().GetEvent())
+}
+
+func TestRecogniseStreamResultHandler(t *testing.T) {
+	interim := &sttv1.RecognitionResult{
+		Alternatives: []*sttv1.RecognitionAlternative{{Transcript: "hel", Words: []*sttv1.WordInfo{word("hel", 0.125, 0.25)}}},
+		Duration:     0.5,
+	}
+	stream := newFakeRecognitionStream(
+		resultResponse(interim),
+		resultResponse(finalResult("hello", 1, word("hello", 0.125, 0.5))),
+		resultResponse(finalResult("world", 1, word("world", 1.25, 1.5))),
+	)
+	recogniser := newFakeRecogniser(stream, DefaultPacing)
+
+	events := make([]RecognitionEvent, 0)
+	config := StreamConfig{
+		RecognitionOptions: RecognitionOptions{Topic: "generic"},
+		SampleRate:         8000,
+		OnResult: func(event RecognitionEvent) {
+			events = append(events, event)
+		},
+	}
+	res, err := recogniser.RecogniseStream(context.Background(), bytes.NewReader(make([]byte, 160)), config)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", res)
+
+	assert.Len(t, events, 3)
+	assert.False(t, events[0].IsFinal)
+	assert.Equal(t, "hel", events[0].Text)
+	assert.Equal(t, 250*time.Millisecond, events[0].EndOfUtteranceSilence)
+	assert.Nil(t, events[0].Segment)
+
+	assert.True(t, events[1].IsFinal)
+	assert.Equal(t, "hello", events[1].Text)
+	assert.Equal(t, 500*time.Millisecond, events[1].EndOfUtteranceSilence)
+	assert.Equal(t, time.Duration(0), events[1].Segment.Start)
+
+	assert.True(t, events[2].IsFinal)
+	assert.Equal(t, 500*time.Millisecond, events[2].EndOfUtteranceSilence)
+	assert.Equal(t, time.Second, events[2].Segment.Start)
+	assert.Equal(t, 2*time.Second, events[2].Segment.End)
+}
+
+func TestRecogniseStreamCancelledWhileSending(t *testing.T) {
+	recogniser := newFakeRecogniser(newFakeRecognitionStream(), DefaultPacing)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := recogniser.RecogniseStream(ctx, bytes.NewReader(make([]byte, 32000)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 16000})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRecogniseStreamCancelledWhileReceiving(t *testing.T) {
+	stream := newFakeRecognitionStream()
+	stream.hang = true
+	recogniser := newFakeRecogniser(stream, DefaultPacing)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := recogniser.RecogniseStream(ctx, bytes.NewReader(make([]byte, 160)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 16000})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	assert.Contains(t, err.Error(), "receiving results")
+}
+
+func TestRecogniseStreamUnreachableEndpoint(t *testing.T) {
+	recogniser, err := NewRecogniser("localhost:1", createTemporaryToken(t))
+	assert.NoError(t, err)
+	defer recogniser.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err = recogniser.RecogniseStream(ctx, bytes.NewReader(make([]byte, 160)), StreamConfig{RecognitionOptions: RecognitionOptions{Topic: "generic"}, SampleRate: 16000})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+}
+
+func TestOpenAudioStreamWav(t *testing.T) {
+	audio, err := os.ReadFile(createTemporaryWav(t, 16000, 16, 2, []int{1, 2, 3, 4}))
+	assert.NoError(t, err)
+
+	samples, sampleRate, channels, err := openAudioStream(bufio.NewReader(bytes.NewReader(audio)), StreamConfig{SampleRate: 8000})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(16000), sampleRate)
+	assert.Equal(t, uint16(2), channels)
+
+	data, err := io.ReadAll(samples)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 0, 2, 0, 3, 0, 4, 0}, data)
+}
+
+func TestOpenAudioStreamExtensibleWav(t *testing.T) {
+	audio := extensibleWav(wavFormatPCM, 16000, 16, 1, []byte{1, 0, 2, 0})
+
+	samples, sampleRate, channels, err := openAudioStream(bufio.NewReader(bytes.NewReader(audio)), StreamConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(16000), sampleRate)
+	assert.Equal(t, uint16(1), channels)
+
+	data, err := io.ReadAll(samples)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 0, 2, 0}, data)
+
+	_, _, _, err = openAudioStream(bufio.NewReader(bytes.NewReader(extensibleWav(wavFormatIEEEFloat, 16000, 32, 1, make([]byte, 4)))), StreamConfig{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported WAV audio format 3")
+}
+
+func TestOpenAudioStreamRaw(t *testing.T) {
+	samples, sampleRate, channels, err := openAudioStream(bufio.NewReader(bytes.NewReader([]byte{1, 0, 2, 0})), StreamConfig{SampleRate: 8000})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8000), sampleRate)
+	assert.Equal(t, uint16(1), channels)
+
+	data, err := io.ReadAll(samples)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 0, 2, 0}, data)
+
+	_, _, _, err = openAudioStream(bufio.NewReader(bytes.NewReader([]byte{1, 0, 2, 0})), StreamConfig{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "needs a sample rate")
+}
+
+func TestOpenAudioStreamUnsupportedWav(t *testing.T) {
+	audio, err := os.ReadFile(createTemporaryWav(t, 16000, 8, 1, []int{128, 128}))
+	assert.NoError(t, err)
+
+	_, _, _, err = openAudioStream(bufio.NewReader(bytes.NewReader(audio)), StreamConfig{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported WAV bit depth 8")
+}
+
+func TestRecogniseStreamStopsAtEndOfUtterance(t *testing.T) {
+	stream := newFakeRecognitionStream(resultResponse(finalResult("after", 1)))
+	// One second of audio whose only word ends at 0.2 s leaves 800 ms of silence.
+	stream.live = []*sttv1.RecognitionStreamingResponse{resultResponse(finalResult("yes", 1, word("yes", 0.1, 0.2)))}
+	recogniser := newFakeRecogniser(stream, Pacing{Speed: 10, ChunkDuration: 50 * time.Millisecond})
+
+	config := StreamConfig{
+		RecognitionOptions: RecognitionOptions{Topic: "generic", EndOfUtteranceSilence: 500 * time.Millisecond},
+		SampleRate:         8000,
+	}
+	transcript, err := recogniser.RecogniseStreamTranscript(context.Background(), bytes.NewReader(make([]byte, 32000)), config)
+	assert.NoError(t, err)
+	assert.Equal(t, "yes after", transcript.Text())
+
+	// 2 s of audio are 40 chunks, far more than sent before stopping.
+	audioRequests := len(stream.requests) - 2
+	assert.True(t, audioRequests > 0 && audioRequests < 40, "sent %d audio chunks", audioRequests)
+	assert.Equal(t, time.Duration(audioRequests)*50*time.Millisecond, transcript.StoppedAt)
+	assert.Equal(t, sttv1.EventMessage_END_OF_STREAM, stream.requests[len(stream.requests)-1].GetEventMessage().GetEvent())
+}
+
+func TestRecogniseStreamEndOfUtteranceBelowThreshold(t *testing.T) {
+	stream := newFakeRecognitionStream()
+	stream.live = []*sttv1.RecognitionStreamingResponse{resultResponse(finalResult("yes", 1, word("yes", 0.1, 0.9)))}
+	recogniser := newFakeRecogniser(stream, Pacing{Speed: 0, ChunkDuration: 50 * time.Millisecond})
+
+	config := StreamConfig{
+		RecognitionOptions: RecognitionOptions{Topic: "generic", EndOfUtteranceSilence: 500 * time.Millisecond},
+		SampleRate:         8000,
+	}
+	transcript, err := recogniser.RecogniseStreamTranscript(context.Background(), bytes.NewReader(make([]byte, 8000)), config)
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", transcript.Text())
+	assert.Equal(t, time.Duration(0), transcript.StoppedAt)
+	assert.Len(t, stream.requests, 12)
+}
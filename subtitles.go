@@ -0,0 +1,177 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SubtitleOptions controls how words are grouped into subtitle cues.
+type SubtitleOptions struct {
+	// MaxLineLength is the maximum number of characters of a cue line.
+	MaxLineLength int
+	// MaxLines is the maximum number of lines of a cue.
+	MaxLines int
+	// MaxCueDuration is the longest time a cue stays on screen.
+	MaxCueDuration time.Duration
+	// MinPause is the gap between two words that starts a new cue.
+	MinPause time.Duration
+}
+
+// DefaultSubtitleOptions follows common broadcast captioning guidelines.
+var DefaultSubtitleOptions = SubtitleOptions{
+	MaxLineLength:  42,
+	MaxLines:       2,
+	MaxCueDuration: 6 * time.Second,
+	MinPause:       700 * time.Millisecond,
+}
+
+// Cue is a piece of text shown on screen between Start and End.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Lines []string
+}
+
+// Text returns the lines of the cue separated by line breaks.
+func (c Cue) Text() string {
+	return strings.Join(c.Lines, "\n")
+}
+
+// BuildCues groups the words of the best alternative of every segment into
+// cues. A new cue starts at the beginning of every segment, after sentence
+// punctuation, after a pause of at least MinPause, and whenever the cue would
+// exceed MaxCueDuration or MaxLines lines of MaxLineLength characters. Zero
+// option values are replaced by their DefaultSubtitleOptions counterparts.
+func BuildCues(transcript *Transcript, opts SubtitleOptions) []Cue {
+	opts = opts.withDefaults()
+
+	cues := make([]Cue, 0)
+	var current *Cue
+	flush := func() {
+		if current != nil {
+			cues = append(cues, *current)
+			current = nil
+		}
+	}
+
+	for _, segment := range transcript.Segments {
+		flush()
+		for _, word := range segmentWords(segment) {
+			if current != nil && (word.Start-current.End >= opts.MinPause || word.End-current.Start > opts.MaxCueDuration) {
+				flush()
+			}
+			if current != nil && !current.fits(word.Text, opts) {
+				flush()
+			}
+			if current == nil {
+				current = &Cue{Start: word.Start, Lines: []string{}}
+			}
+			current.add(word.Text, opts)
+			if word.End > current.End {
+				current.End = word.End
+			}
+			if endsSentence(word.Text) {
+				flush()
+			}
+		}
+	}
+	flush()
+
+	// Cues must not overlap, otherwise players show them on top of each other.
+	for i := 0; i < len(cues)-1; i++ {
+		if cues[i].End > cues[i+1].Start {
+			cues[i].End = cues[i+1].Start
+		}
+	}
+	return cues
+}
+
+func (o SubtitleOptions) withDefaults() SubtitleOptions {
+	if o.MaxLineLength <= 0 {
+		o.MaxLineLength = DefaultSubtitleOptions.MaxLineLength
+	}
+	if o.MaxLines <= 0 {
+		o.MaxLines = DefaultSubtitleOptions.MaxLines
+	}
+	if o.MaxCueDuration <= 0 {
+		o.MaxCueDuration = DefaultSubtitleOptions.MaxCueDuration
+	}
+	if o.MinPause <= 0 {
+		o.MinPause = DefaultSubtitleOptions.MinPause
+	}
+	return o
+}
+
+// segmentWords returns the words of a segment. Segments reported without word
+// timings are split into words spread evenly over the segment.
+func segmentWords(segment Segment) []Word {
+	if len(segment.Words) > 0 {
+		return segment.Words
+	}
+	texts := strings.Fields(segment.Text)
+	words := make([]Word, len(texts))
+	step := (segment.End - segment.Start) / time.Duration(max(len(texts), 1))
+	for i, text := range texts {
+		words[i] = Word{
+			Text:  text,
+			Start: segment.Start + time.Duration(i)*step,
+			End:   segment.Start + time.Duration(i+1)*step,
+		}
+	}
+	return words
+}
+
+func (c *Cue) fits(word string, opts SubtitleOptions) bool {
+	if len(c.Lines) == 0 || len(c.Lines) < opts.MaxLines {
+		return true
+	}
+	last := c.Lines[len(c.Lines)-1]
+	return utf8.RuneCountInString(last)+1+utf8.RuneCountInString(word) <= opts.MaxLineLength
+}
+
+func (c *Cue) add(word string, opts SubtitleOptions) {
+	if len(c.Lines) > 0 {
+		last := &c.Lines[len(c.Lines)-1]
+		if utf8.RuneCountInString(*last)+1+utf8.RuneCountInString(word) <= opts.MaxLineLength {
+			*last += " " + word
+			return
+		}
+	}
+	c.Lines = append(c.Lines, word)
+}
+
+func endsSentence(word string) bool {
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, "!")
+}
+
+// WriteSRT writes cues in the SubRip format.
+func WriteSRT(w io.Writer, cues []Cue) error {
+	out := bufio.NewWriter(w)
+	for i, cue := range cues {
+		fmt.Fprintf(out, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(cue.Start, ','), formatTimestamp(cue.End, ','), cue.Text())
+	}
+	return out.Flush()
+}
+
+// WriteVTT writes cues in the WebVTT format.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprint(out, "WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(out, "%s --> %s\n%s\n\n", formatTimestamp(cue.Start, '.'), formatTimestamp(cue.End, '.'), cue.Text())
+	}
+	return out.Flush()
+}
+
+func formatTimestamp(d time.Duration, separator rune) string {
+	if d < 0 {
+		d = 0
+	}
+	milliseconds := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d",
+		milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, separator, milliseconds%1000)
+}
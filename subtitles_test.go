@@ -0,0 +1,84 @@
+package verbio_speech_center
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func timedWord(text string, start int, end int) Word {
+	return Word{Text: text, Start: time.Duration(start) * time.Millisecond, End: time.Duration(end) * time.Millisecond}
+}
+
+func TestBuildCuesSplitsAtPunctuationAndPauses(t *testing.T) {
+	transcript := &Transcript{Segments: []Segment{{
+		Words: []Word{
+			timedWord("Hello", 0, 400),
+			timedWord("there.", 450, 900),
+			timedWord("How", 1000, 1200),
+			timedWord("are", 1250, 1400),
+			timedWord("you", 1450, 1600),
+			timedWord("doing", 3000, 3400),
+		},
+	}}}
+
+	cues := BuildCues(transcript, SubtitleOptions{})
+	assert.Equal(t, []Cue{
+		{Start: 0, End: 900 * time.Millisecond, Lines: []string{"Hello there."}},
+		{Start: time.Second, End: 1600 * time.Millisecond, Lines: []string{"How are you"}},
+		{Start: 3 * time.Second, End: 3400 * time.Millisecond, Lines: []string{"doing"}},
+	}, cues)
+}
+
+func TestBuildCuesLineLengthAndDuration(t *testing.T) {
+	words := make([]Word, 0)
+	for i := 0; i < 8; i++ {
+		words = append(words, timedWord("word", i*500, i*500+400))
+	}
+	transcript := &Transcript{Segments: []Segment{{Words: words}}}
+
+	cues := BuildCues(transcript, SubtitleOptions{MaxLineLength: 10, MaxLines: 2, MaxCueDuration: 10 * time.Second})
+	assert.Len(t, cues, 2)
+	assert.Equal(t, []string{"word word", "word word"}, cues[0].Lines)
+	assert.Equal(t, 1900*time.Millisecond, cues[0].End)
+	assert.Equal(t, 2*time.Second, cues[1].Start)
+
+	cues = BuildCues(transcript, SubtitleOptions{MaxLineLength: 100, MaxCueDuration: 1500 * time.Millisecond})
+	assert.Len(t, cues, 3)
+	assert.Equal(t, []string{"word word word"}, cues[0].Lines)
+}
+
+func TestBuildCuesWithoutWordTimings(t *testing.T) {
+	transcript := &Transcript{Segments: []Segment{
+		{Start: 0, End: 2 * time.Second, Text: "one two"},
+		{Start: 2 * time.Second, End: 3 * time.Second, Text: "three"},
+	}}
+
+	cues := BuildCues(transcript, DefaultSubtitleOptions)
+	assert.Equal(t, []Cue{
+		{Start: 0, End: 2 * time.Second, Lines: []string{"one two"}},
+		{Start: 2 * time.Second, End: 3 * time.Second, Lines: []string{"three"}},
+	}, cues)
+}
+
+func TestWriteSRT(t *testing.T) {
+	cues := []Cue{
+		{Start: 1234 * time.Millisecond, End: 2500 * time.Millisecond, Lines: []string{"Hello there."}},
+		{Start: time.Hour + 61*time.Second, End: time.Hour + 62*time.Second, Lines: []string{"How are", "you?"}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSRT(&buf, cues))
+	assert.Equal(t, "1\n00:00:01,234 --> 00:00:02,500\nHello there.\n\n"+
+		"2\n01:01:01,000 --> 01:01:02,000\nHow are\nyou?\n\n", buf.String())
+}
+
+func TestWriteVTT(t *testing.T) {
+	cues := []Cue{{Start: 1234 * time.Millisecond, End: 2500 * time.Millisecond, Lines: []string{"Hello there."}}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteVTT(&buf, cues))
+	assert.Equal(t, "WEBVTT\n\n00:00:01.234 --> 00:00:02.500\nHello there.\n\n", buf.String())
+}
@@ -15,16 +15,18 @@ import (
 	"google.golang.org/grpc"
 )
 
-func (s *Synthesizer) getStreamingClient() error {
-	var err error
-	s.stream, err = s.client.StreamingSynthesizeSpeech(context.Background(), grpc.WaitForReady(true))
+func (s *Synthesizer) getStreamingClient(ctx context.Context) (*synthesisSession, error) {
+	stream, err := s.client.StreamingSynthesizeSpeech(ctx, grpc.WaitForReady(true))
 	if err != nil {
-		return fmt.Errorf("error obtaining streaming client: %+v", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("error obtaining streaming client: %w", ctx.Err())
+		}
+		return nil, synthesisFailure(fmt.Errorf("error obtaining streaming client: %w", err))
 	}
-	return nil
+	return &synthesisSession{stream: stream}, nil
 }
 
-func (s *Synthesizer) sendConfig(voice string, samplingRate ttsv1.VoiceSamplingRate) error {
+func (s *synthesisSession) sendConfig(voice string, samplingRate ttsv1.VoiceSamplingRate) error {
 	config := &ttsv1.StreamingSynthesisRequest{
 		SynthesisRequest: &ttsv1.StreamingSynthesisRequest_Config{
 			Config: &ttsv1.SynthesisConfig{
@@ -34,41 +36,41 @@ func (s *Synthesizer) sendConfig(voice string, samplingRate ttsv1.VoiceSamplingR
 		},
 	}
 	if err := s.stream.Send(config); err != nil {
-		return fmt.Errorf("error sending config: %+v", err)
+		return fmt.Errorf("error sending config: %w", err)
 	}
 	log.Logger.Debugf("Sent config")
 	return nil
 }
 
-func (s *Synthesizer) sendText(text string) error {
+func (s *synthesisSession) sendText(text string) error {
 	textReq := &ttsv1.StreamingSynthesisRequest{
 		SynthesisRequest: &ttsv1.StreamingSynthesisRequest_Text{
 			Text: text,
 		},
 	}
 	if err := s.stream.Send(textReq); err != nil {
-		return fmt.Errorf("error sending text: %+v", err)
+		return fmt.Errorf("error sending text: %w", err)
 	}
 	log.Logger.Debugf("Sent text")
 	return nil
 }
 
-func (s *Synthesizer) sendEndOfUtterance() error {
+func (s *synthesisSession) sendEndOfUtterance() error {
 	endReq := &ttsv1.StreamingSynthesisRequest{
 		SynthesisRequest: &ttsv1.StreamingSynthesisRequest_EndOfUtterance{
 			EndOfUtterance: &ttsv1.EndOfUtterance{},
 		},
 	}
 	if err := s.stream.Send(endReq); err != nil {
-		return fmt.Errorf("error sending end of utterance: %+v", err)
+		return fmt.Errorf("error sending end of utterance: %w", err)
 	}
 	log.Logger.Debugf("Sent end of utterance")
 	return nil
 }
 
-func (s *Synthesizer) closeSend() error {
+func (s *synthesisSession) closeSend() error {
 	if err := s.stream.CloseSend(); err != nil {
-		return fmt.Errorf("error closing send: %+v", err)
+		return fmt.Errorf("error closing send: %w", err)
 	}
 	return nil
 }
@@ -78,7 +80,9 @@ type audioResult struct {
 	err       error
 }
 
-func (s *Synthesizer) collectAudioChunks(c chan audioResult) chan audioResult {
+// collectAudioChunks receives audio until the stream ends or an end of
+// utterance arrives.
+func (s *synthesisSession) collectAudioChunks() audioResult {
 	var allAudioData []byte
 	log.Logger.Debugf("> Waiting for audio responses ...")
 	for {
@@ -88,8 +92,7 @@ func (s *Synthesizer) collectAudioChunks(c chan audioResult) chan audioResult {
 			break
 		}
 		if err != nil {
-			c <- audioResult{audioData: nil, err: fmt.Errorf("error receiving audio: %+v", err)}
-			return c
+			return audioResult{audioData: nil, err: fmt.Errorf("error receiving audio: %w", err)}
 		}
 
 		if audio := resp.GetStreamingAudio(); audio != nil {
@@ -103,37 +106,90 @@ func (s *Synthesizer) collectAudioChunks(c chan audioResult) chan audioResult {
 	}
 
 	if len(allAudioData) == 0 {
-		c <- audioResult{audioData: nil, err: errors.New("received no audio data")}
-		return c
+		return audioResult{audioData: nil, err: synthesisError(ErrNoAudio, ErrNoAudio)}
 	}
 
 	log.Logger.Debugf("< all audio responses received")
-	c <- audioResult{audioData: allAudioData, err: nil}
-	return c
+	return audioResult{audioData: allAudioData, err: nil}
 }
 
-func (s *Synthesizer) StreamingSynthesizeSpeech(text string, voice string, samplingRate ttsv1.VoiceSamplingRate, format ttsv1.AudioFormat, outputFile string) error {
+func (s *Synthesizer) StreamingSynthesizeSpeech(ctx context.Context, text string, voice string, samplingRate ttsv1.VoiceSamplingRate, format ttsv1.AudioFormat, outputFile string) error {
 	log.Logger.Infof("Streaming synthesis [text=%s] [voice=%s] [samplingRate=%v] [format=%v] [outputFile=%s]", text, voice, samplingRate, format, outputFile)
 
 	if text == "" {
-		return errors.New("text cannot be empty")
+		return synthesisError(ErrInvalidOptions, errors.New("text cannot be empty"))
 	}
 	if voice == "" {
-		return errors.New("voice cannot be empty")
+		return synthesisError(ErrInvalidOptions, errors.New("voice cannot be empty"))
 	}
 	if outputFile == "" {
-		return errors.New("output file cannot be empty")
+		return synthesisError(ErrInvalidOptions, errors.New("output file cannot be empty"))
 	}
 
-	if err := s.getStreamingClient(); err != nil {
+	var allAudioData []byte
+	err := s.options.retry.do(ctx, "synthesis", func(ctx context.Context) (bool, error) {
+		var err error
+		allAudioData, err = s.performSynthesis(ctx, text, voice, samplingRate)
+		return true, err
+	})
+	if err != nil {
 		return err
 	}
 
-	c := make(chan audioResult)
+	if format == ttsv1.AudioFormat_AUDIO_FORMAT_WAV_LPCM_S16LE {
+		err = saveWavAudio(outputFile, allAudioData, samplingRate)
+	} else {
+		err = saveRawAudio(outputFile, allAudioData)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving audio file: %w", err)
+	}
+
+	log.Logger.Infof("Successfully saved %d bytes of audio to %s", len(allAudioData), outputFile)
+	return nil
+}
+
+// performSynthesis synthesizes text on a new stream and returns its audio.
+func (s *Synthesizer) performSynthesis(ctx context.Context, text string, voice string, samplingRate ttsv1.VoiceSamplingRate) ([]byte, error) {
+	ctx, release, err := s.sessions.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	halves := newDuplex(ctx)
+	defer halves.cancel()
+
+	session, err := s.getStreamingClient(halves.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make(chan audioResult, 1)
 	go func() {
-		c = s.collectAudioChunks(c)
+		result := session.collectAudioChunks()
+		if result.err != nil {
+			halves.fail(result.err)
+		}
+		received <- result
 	}()
 
+	sendErr := halves.sent(session.sendRequests(text, voice, samplingRate))
+
+	log.Logger.Info("Waiting for audio collection to finish")
+	result := <-received
+	if ctx.Err() != nil {
+		if sendErr != nil {
+			return nil, fmt.Errorf("synthesis interrupted while sending text: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("synthesis interrupted while receiving audio: %w", ctx.Err())
+	}
+	if halves.err != nil {
+		return nil, synthesisFailure(halves.err)
+	}
+	return result.audioData, nil
+}
+
+func (s *synthesisSession) sendRequests(text string, voice string, samplingRate ttsv1.VoiceSamplingRate) error {
 	if err := s.sendConfig(voice, samplingRate); err != nil {
 		return err
 	}
@@ -146,36 +202,13 @@ func (s *Synthesizer) StreamingSynthesizeSpeech(text string, voice string, sampl
 		return err
 	}
 
-	if err := s.closeSend(); err != nil {
-		return err
-	}
-
-	log.Logger.Info("Waiting for audio collection to finish")
-	result := <-c
-	if result.err != nil {
-		return result.err
-	}
-
-	allAudioData := result.audioData
-
-	var err error
-	if format == ttsv1.AudioFormat_AUDIO_FORMAT_WAV_LPCM_S16LE {
-		err = saveWavAudio(outputFile, allAudioData, samplingRate)
-	} else {
-		err = saveRawAudio(outputFile, allAudioData)
-	}
-	if err != nil {
-		return fmt.Errorf("error saving audio file: %+v", err)
-	}
-
-	log.Logger.Infof("Successfully saved %d bytes of audio to %s", len(allAudioData), outputFile)
-	return nil
+	return s.closeSend()
 }
 
 func saveRawAudio(file string, pcmData []byte) error {
 	err := os.WriteFile(file, pcmData, 0644)
 	if err != nil {
-		return fmt.Errorf("error writing raw audio file: %+v", err)
+		return fmt.Errorf("error writing raw audio file: %w", err)
 	}
 	return nil
 }
@@ -209,7 +242,7 @@ func saveWavAudio(file string, pcmData []byte, samplingRate ttsv1.VoiceSamplingR
 
 	outFile, err := os.Create(file)
 	if err != nil {
-		return fmt.Errorf("error creating WAV file: %+v", err)
+		return fmt.Errorf("error creating WAV file: %w", err)
 	}
 
 	defer func() {
@@ -223,10 +256,10 @@ func saveWavAudio(file string, pcmData []byte, samplingRate ttsv1.VoiceSamplingR
 	numChannels := 1
 	enc := wav.NewEncoder(outFile, sampleRate, bitDepth, numChannels, format)
 	if err := enc.Write(audioBuffer); err != nil {
-		return fmt.Errorf("error encoding WAV: %+v", err)
+		return fmt.Errorf("error encoding WAV: %w", err)
 	}
 	if err := enc.Close(); err != nil {
-		return fmt.Errorf("error closing WAV encoder: %+v", err)
+		return fmt.Errorf("error closing WAV encoder: %w", err)
 	}
 
 	return nil
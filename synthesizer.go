@@ -1,45 +1,67 @@
 package verbio_speech_center
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strings"
 	"verbio_speech_center/log"
 	pb "verbio_speech_center/proto/speechcenter/tts"
 
 	"google.golang.org/grpc"
 )
 
+// Synthesizer is safe for concurrent use: every synthesis runs on its own
+// stream over the shared connection.
 type Synthesizer struct {
-	conn   *grpc.ClientConn
-	client pb.TextToSpeechClient
-	stream grpc.BidiStreamingClient[pb.StreamingSynthesisRequest, pb.StreamingSynthesisResponse]
+	conn     *grpc.ClientConn
+	client   pb.TextToSpeechClient
+	options  clientOptions
+	sessions sessionTracker
 }
 
-func NewSynthesizer(url string, tokenFile string) (*Synthesizer, error) {
+// NewSynthesizer connects to the text-to-speech service at url. Of the
+// options, only WithRetry and WithClientCredentials apply to synthesis.
+func NewSynthesizer(url string, tokenFile string, opts ...Option) (*Synthesizer, error) {
 	if err := validateURL(url); err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	token, err := loadToken(tokenFile)
-	log.Logger.Infof("Loaded token from file: [%s]", tokenFile)
+	options, err := newClientOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option: %w", err)
+	}
+
+	tokens, err := options.credentials(tokenFile)
 	if err != nil {
 		return nil, err
 	}
 
-	token = strings.TrimSpace(token)
-	conn, err := initConnection(url, token)
+	conn, err := initConnection(url, tokens)
 	log.Logger.Infof("Established connection to the URL: [%s]", url)
 	if err != nil {
-		return nil, fmt.Errorf("error establishing connection: %+v", err)
+		return nil, fmt.Errorf("error establishing connection: %w", err)
 	}
 
 	client := pb.NewTextToSpeechClient(conn)
 	return &Synthesizer{
-		conn:   conn,
-		client: client,
+		conn:    conn,
+		client:  client,
+		options: options,
 	}, nil
 }
 
+// Close cancels the syntheses in flight, waits for them to return and closes
+// the connection. When syntheses had to be cancelled, the error returned
+// matches context.Canceled.
 func (s *Synthesizer) Close() error {
-	return s.conn.Close()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	return errors.Join(s.sessions.shutdown(cancelled), s.conn.Close())
+}
+
+// Shutdown stops accepting new syntheses and waits for the ones in flight to
+// finish before closing the connection. If ctx is done first, the remaining
+// syntheses are cancelled and the context error is returned.
+func (s *Synthesizer) Shutdown(ctx context.Context) error {
+	return errors.Join(s.sessions.shutdown(ctx), s.conn.Close())
 }
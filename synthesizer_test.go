@@ -1,7 +1,12 @@
 package verbio_speech_center
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
+	ttsv1 "verbio_speech_center/proto/speechcenter/tts"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -101,3 +106,19 @@ func TestValidateURL(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamingSynthesizeSpeechUnreachableEndpoint(t *testing.T) {
+	s, err := NewSynthesizer("localhost:1", createTemporaryToken(t))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = s.StreamingSynthesizeSpeech(ctx, "hello", "voice", ttsv1.VoiceSamplingRate_VOICE_SAMPLING_RATE_16KHZ,
+		ttsv1.AudioFormat_AUDIO_FORMAT_WAV_LPCM_S16LE, filepath.Join(t.TempDir(), "out.wav"))
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
@@ -0,0 +1,43 @@
+package verbio_speech_center
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+)
+
+// Topics lists the names of the recognition topics the service knows, in
+// lowercase and in the order of the proto enum.
+func Topics() []string {
+	topics := enumNames(sttv1.RecognitionResource_Topic_name)
+	for i, topic := range topics {
+		topics[i] = strings.ToLower(topic)
+	}
+	return topics
+}
+
+// enumNames lists the names of a proto enum, given its name map, in the
+// order of their numbers.
+func enumNames(names map[int32]string) []string {
+	numbers := make([]int32, 0, len(names))
+	for number := range names {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	sorted := make([]string, len(numbers))
+	for i, number := range numbers {
+		sorted[i] = names[number]
+	}
+	return sorted
+}
+
+// ParseTopic returns the topic named by name, ignoring case.
+func ParseTopic(name string) (sttv1.RecognitionResource_Topic, error) {
+	number, ok := sttv1.RecognitionResource_Topic_value[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unrecognized topic: %s (must be one of %s)", name, strings.Join(Topics(), ", "))
+	}
+	return sttv1.RecognitionResource_Topic(number), nil
+}
@@ -0,0 +1,38 @@
+package verbio_speech_center
+
+import (
+	"testing"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopics(t *testing.T) {
+	topics := Topics()
+	assert.Len(t, topics, len(sttv1.RecognitionResource_Topic_name))
+	assert.Equal(t, "generic", topics[0])
+	assert.Contains(t, topics, "banking")
+}
+
+func TestParseTopic(t *testing.T) {
+	topic, err := ParseTopic("Banking")
+	assert.NoError(t, err)
+	assert.Equal(t, sttv1.RecognitionResource_BANKING, topic)
+
+	topic, err = ParseTopic("TELCO")
+	assert.NoError(t, err)
+	assert.Equal(t, sttv1.RecognitionResource_TELCO, topic)
+
+	_, err = ParseTopic("weather")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "generic, banking, telco, insurance")
+}
+
+func TestTopicRequest(t *testing.T) {
+	request, err := RecognitionOptions{Topic: "insurance", Language: "en-US"}.request(GrammarAuto, 8000, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, sttv1.RecognitionResource_INSURANCE, request.GetConfig().GetResource().GetTopic())
+
+	_, err = RecognitionOptions{Topic: "weather", Language: "en-US"}.request(GrammarAuto, 8000, 1)
+	assert.Error(t, err)
+}
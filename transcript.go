@@ -0,0 +1,119 @@
+package verbio_speech_center
+
+import (
+	"strings"
+	"time"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+)
+
+// Transcript is the structured outcome of a recognition: one segment per
+// final result, in the order they were received.
+type Transcript struct {
+	Segments []Segment
+	// StoppedAt is the offset of the audio at which streaming stopped after
+	// the end of the utterance was detected. It is zero when all of the audio
+	// was sent.
+	StoppedAt time.Duration
+	// Reconnects records every stream that failed and was resumed on a new
+	// one, in order.
+	Reconnects []ReconnectEvent
+}
+
+// Segment is a final recognition result, usually a single utterance.
+type Segment struct {
+	// Start and End are offsets from the beginning of the audio.
+	Start time.Duration
+	End   time.Duration
+	// Text, Confidence and Words belong to the best alternative.
+	Text       string
+	Confidence float32
+	Words      []Word
+	// Alternatives holds every hypothesis returned for the segment, best first.
+	Alternatives []Alternative
+}
+
+// Alternative is one of the n-best hypotheses of a segment.
+type Alternative struct {
+	Text       string
+	Confidence float32
+	Words      []Word
+}
+
+// Word is a recognised word and its position in the audio.
+type Word struct {
+	Text string
+	// Start and End are offsets from the beginning of the audio.
+	Start      time.Duration
+	End        time.Duration
+	Confidence float32
+}
+
+// Text returns the best transcript of every segment joined by spaces.
+func (t *Transcript) Text() string {
+	texts := make([]string, 0, len(t.Segments))
+	for _, segment := range t.Segments {
+		texts = append(texts, segment.Text)
+	}
+	return strings.Join(texts, " ")
+}
+
+// Words returns the words of the best alternative of every segment.
+func (t *Transcript) Words() []Word {
+	words := make([]Word, 0)
+	for _, segment := range t.Segments {
+		words = append(words, segment.Words...)
+	}
+	return words
+}
+
+// newTranscript builds a Transcript out of final results. Segment offsets are
+// the accumulated durations of the previous results, while word timings are
+// reported by the service relative to the start of the stream already.
+func newTranscript(results []*sttv1.RecognitionResult) *Transcript {
+	transcript := &Transcript{Segments: make([]Segment, 0, len(results))}
+	offset := float32(0)
+	for _, result := range results {
+		transcript.Segments = append(transcript.Segments, newSegment(result, offset))
+		offset += result.Duration
+	}
+	return transcript
+}
+
+// newSegment builds the segment of a result that starts offset seconds into the audio.
+func newSegment(result *sttv1.RecognitionResult, offset float32) Segment {
+	segment := Segment{
+		Start:        secondsToDuration(offset),
+		End:          secondsToDuration(offset + result.Duration),
+		Alternatives: make([]Alternative, 0, len(result.Alternatives)),
+	}
+	for _, alternative := range result.Alternatives {
+		segment.Alternatives = append(segment.Alternatives, newAlternative(alternative))
+	}
+	if len(segment.Alternatives) > 0 {
+		segment.Text = segment.Alternatives[0].Text
+		segment.Confidence = segment.Alternatives[0].Confidence
+		segment.Words = segment.Alternatives[0].Words
+	}
+	return segment
+}
+
+func newAlternative(alternative *sttv1.RecognitionAlternative) Alternative {
+	words := make([]Word, 0, len(alternative.Words))
+	for _, word := range alternative.Words {
+		words = append(words, Word{
+			Text:       word.Word,
+			Start:      secondsToDuration(word.StartTime),
+			End:        secondsToDuration(word.EndTime),
+			Confidence: word.Confidence,
+		})
+	}
+	return Alternative{
+		Text:       alternative.Transcript,
+		Confidence: alternative.Confidence,
+		Words:      words,
+	}
+}
+
+func secondsToDuration(seconds float32) time.Duration {
+	return time.Duration(float64(seconds) * float64(time.Second))
+}
@@ -0,0 +1,63 @@
+package verbio_speech_center
+
+import (
+	"testing"
+	"time"
+	sttv1 "verbio_speech_center/proto/speechcenter/stt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTranscript(t *testing.T) {
+	results := []*sttv1.RecognitionResult{
+		{
+			Alternatives: []*sttv1.RecognitionAlternative{
+				{
+					Transcript: "hello world",
+					Confidence: 0.9,
+					Words: []*sttv1.WordInfo{
+						{Word: "hello", StartTime: 0.25, EndTime: 0.5, Confidence: 0.95},
+						{Word: "world", StartTime: 0.75, EndTime: 1.5, Confidence: 0.85},
+					},
+				},
+				{Transcript: "hello word", Confidence: 0.4},
+			},
+			Duration: 2,
+			IsFinal:  true,
+		},
+		finalResult("goodbye", 1.5, word("goodbye", 2.5, 3)),
+	}
+
+	transcript := newTranscript(results)
+	assert.Len(t, transcript.Segments, 2)
+	assert.Equal(t, "hello world goodbye", transcript.Text())
+
+	first := transcript.Segments[0]
+	assert.Equal(t, time.Duration(0), first.Start)
+	assert.Equal(t, 2*time.Second, first.End)
+	assert.Equal(t, "hello world", first.Text)
+	assert.Equal(t, float32(0.9), first.Confidence)
+	assert.Equal(t, []Word{
+		{Text: "hello", Start: 250 * time.Millisecond, End: 500 * time.Millisecond, Confidence: 0.95},
+		{Text: "world", Start: 750 * time.Millisecond, End: 1500 * time.Millisecond, Confidence: 0.85},
+	}, first.Words)
+	assert.Len(t, first.Alternatives, 2)
+	assert.Equal(t, "hello word", first.Alternatives[1].Text)
+	assert.Equal(t, float32(0.4), first.Alternatives[1].Confidence)
+
+	second := transcript.Segments[1]
+	assert.Equal(t, 2*time.Second, second.Start)
+	assert.Equal(t, 3500*time.Millisecond, second.End)
+
+	words := transcript.Words()
+	assert.Len(t, words, 3)
+	assert.Equal(t, "goodbye", words[2].Text)
+	assert.Equal(t, 2500*time.Millisecond, words[2].Start)
+}
+
+func TestNewTranscriptEmpty(t *testing.T) {
+	transcript := newTranscript(nil)
+	assert.Empty(t, transcript.Segments)
+	assert.Equal(t, "", transcript.Text())
+	assert.Empty(t, transcript.Words())
+}
@@ -0,0 +1,276 @@
+package verbio_speech_center
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"verbio_speech_center/log"
+)
+
+// The service documents no limits on word boosting, so lists past these sizes
+// are only reported when they are loaded, and sent as they are.
+const (
+	largeBoostingList   = 1000
+	longBoostedTermSize = 100
+)
+
+// BoostedTerm is a term of a word boosting list.
+type BoostedTerm struct {
+	Term string `json:"term"`
+	// Weight ranks the term against the others of the list. The service takes
+	// an unweighted list, so terms are sent highest weight first.
+	Weight float64 `json:"weight,omitempty"`
+	// Language is the section the term belongs to. Terms without a language
+	// are boosted for every language.
+	Language string `json:"language,omitempty"`
+}
+
+// BoostingList is a word boosting list loaded from a file, with common terms
+// and per-language sections.
+type BoostingList struct {
+	Terms []BoostedTerm
+	index map[string]int
+}
+
+// LoadBoostingList reads a word boosting list. Files ending in .csv need a
+// header with a term column and optional weight and language columns. Files
+// ending in .json hold an array of terms, either strings or objects with
+// term, weight and language, or an object mapping languages to such arrays.
+// Any other file is text with one term per line, optionally followed by
+// "| weight", and "[language]" lines starting a section. In text and CSV files
+// lines starting with # are comments. Repeated terms keep their highest
+// weight.
+func LoadBoostingList(file string) (*BoostingList, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening word boosting list: %w", err)
+	}
+	defer f.Close()
+
+	list := &BoostingList{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		err = list.readCSV(f)
+	case ".json":
+		err = list.readJSON(f)
+	default:
+		err = list.readText(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	list.reportSize(file)
+	return list, nil
+}
+
+// reportSize warns about lists large enough that the service may turn them
+// down or slow down recognition.
+func (l *BoostingList) reportSize(file string) {
+	if len(l.Terms) > largeBoostingList {
+		log.Logger.Warnf("Word boosting list %s has %d terms, more than %d may be rejected by the service", file, len(l.Terms), largeBoostingList)
+	}
+	for _, term := range l.Terms {
+		if length := len([]rune(term.Term)); length > longBoostedTermSize {
+			log.Logger.Warnf("Word boosting term %q is %d characters long, longer than %d may be rejected by the service", term.Term, length, longBoostedTermSize)
+		}
+	}
+}
+
+func (l *BoostingList) readText(r io.Reader) error {
+	language := ""
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			language = strings.TrimSpace(text[1 : len(text)-1])
+			if language == "*" {
+				language = ""
+			}
+			continue
+		}
+
+		term, weight := text, ""
+		if i := strings.LastIndex(text, "|"); i >= 0 {
+			term, weight = text[:i], text[i+1:]
+		}
+		if err := l.addField(term, weight, language); err != nil {
+			return fmt.Errorf("word boosting list line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading word boosting list: %w", err)
+	}
+	return nil
+}
+
+func (l *BoostingList) readCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading word boosting list header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["term"]; !ok {
+		return errors.New("word boosting list header has no term column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading word boosting list: %w", err)
+		}
+		if err := l.addField(field(record, "term"), field(record, "weight"), field(record, "language")); err != nil {
+			line, _ := reader.FieldPos(0)
+			return fmt.Errorf("word boosting list line %d: %w", line, err)
+		}
+	}
+}
+
+func (l *BoostingList) readJSON(r io.Reader) error {
+	var contents json.RawMessage
+	if err := json.NewDecoder(r).Decode(&contents); err != nil {
+		return fmt.Errorf("error parsing word boosting list: %w", err)
+	}
+
+	sections := map[string]json.RawMessage{"": contents}
+	if trimmed := strings.TrimSpace(string(contents)); strings.HasPrefix(trimmed, "{") {
+		sections = nil
+		if err := json.Unmarshal(contents, &sections); err != nil {
+			return fmt.Errorf("error parsing word boosting list: %w", err)
+		}
+	}
+
+	languages := make([]string, 0, len(sections))
+	for language := range sections {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	for _, language := range languages {
+		var entries []json.RawMessage
+		if err := json.Unmarshal(sections[language], &entries); err != nil {
+			return fmt.Errorf("error parsing word boosting list: %w", err)
+		}
+		if language == "*" {
+			language = ""
+		}
+		for i, entry := range entries {
+			term := BoostedTerm{Language: language}
+			if err := json.Unmarshal(entry, &term.Term); err != nil {
+				if err := json.Unmarshal(entry, &term); err != nil {
+					return fmt.Errorf("error parsing word boosting list entry %d: %w", i+1, err)
+				}
+				if term.Language == "" {
+					term.Language = language
+				}
+			}
+			if err := l.add(term); err != nil {
+				return fmt.Errorf("word boosting list entry %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// addField adds a term whose weight is still text.
+func (l *BoostingList) addField(term, weight, language string) error {
+	parsed := 0.0
+	if weight = strings.TrimSpace(weight); weight != "" {
+		var err error
+		if parsed, err = strconv.ParseFloat(weight, 64); err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid weight %q (must be a positive number)", weight)
+		}
+	}
+	return l.add(BoostedTerm{Term: term, Weight: parsed, Language: strings.TrimSpace(language)})
+}
+
+// add validates term and adds it to the list, or raises the weight of the
+// same term already in its section.
+func (l *BoostingList) add(term BoostedTerm) error {
+	term.Term = strings.Join(strings.Fields(term.Term), " ")
+	if term.Term == "" {
+		return errors.New("empty term")
+	}
+	if term.Weight == 0 {
+		term.Weight = 1
+	}
+	if term.Weight < 0 || math.IsNaN(term.Weight) || math.IsInf(term.Weight, 0) {
+		return fmt.Errorf("weight of %q must be a positive number", term.Term)
+	}
+
+	if l.index == nil {
+		l.index = make(map[string]int)
+	}
+	key := strings.ToLower(term.Language) + "\x00" + strings.ToLower(term.Term)
+	if i, ok := l.index[key]; ok {
+		l.Terms[i].Weight = math.Max(l.Terms[i].Weight, term.Weight)
+		return nil
+	}
+	l.index[key] = len(l.Terms)
+	l.Terms = append(l.Terms, term)
+	return nil
+}
+
+// Words returns the common terms and those of the section of language,
+// highest weight first and without duplicates. A section also applies to
+// every region of its language, e.g. "es" to "es-ES".
+func (l *BoostingList) Words(language string) []string {
+	primary, _, _ := strings.Cut(language, "-")
+	terms := make([]BoostedTerm, 0, len(l.Terms))
+	seen := make(map[string]int)
+	for _, term := range l.Terms {
+		if term.Language != "" && !strings.EqualFold(term.Language, language) && !strings.EqualFold(term.Language, primary) {
+			continue
+		}
+		key := strings.ToLower(term.Term)
+		if i, ok := seen[key]; ok {
+			terms[i].Weight = math.Max(terms[i].Weight, term.Weight)
+			continue
+		}
+		seen[key] = len(terms)
+		terms = append(terms, term)
+	}
+	sort.SliceStable(terms, func(i, j int) bool { return terms[i].Weight > terms[j].Weight })
+
+	words := make([]string, len(terms))
+	for i, term := range terms {
+		words[i] = term.Term
+	}
+	return words
+}
+
+// validateWordBoosting checks that words has no empty terms.
+func validateWordBoosting(words []string) error {
+	for _, word := range words {
+		if strings.TrimSpace(word) == "" {
+			return errors.New("word boosting cannot contain empty words")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package verbio_speech_center
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBoostingListText(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "catalogue.txt", []byte(`# Product catalogue
+Verbio
+speech   center | 3
+verbio | 2
+
+[es]
+centralita | 5
+[en-US]
+switchboard
+[*]
+call centre | 0.5
+`))
+	list, err := LoadBoostingList(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []BoostedTerm{
+		{Term: "Verbio", Weight: 2},
+		{Term: "speech center", Weight: 3},
+		{Term: "centralita", Weight: 5, Language: "es"},
+		{Term: "switchboard", Weight: 1, Language: "en-US"},
+		{Term: "call centre", Weight: 0.5},
+	}, list.Terms)
+
+	assert.Equal(t, []string{"centralita", "speech center", "Verbio", "call centre"}, list.Words("es-ES"))
+	assert.Equal(t, []string{"speech center", "Verbio", "switchboard", "call centre"}, list.Words("en-us"))
+	assert.Equal(t, []string{"speech center", "Verbio", "call centre"}, list.Words("pt-BR"))
+}
+
+func TestLoadBoostingListCSV(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "catalogue.csv", []byte(`term,weight,language
+# retired products are commented out
+Verbio,2,
+"Speech Center, Cloud",,en-US
+Verbio,4,
+`))
+	list, err := LoadBoostingList(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []BoostedTerm{
+		{Term: "Verbio", Weight: 4},
+		{Term: "Speech Center, Cloud", Weight: 1, Language: "en-US"},
+	}, list.Terms)
+}
+
+func TestLoadBoostingListJSON(t *testing.T) {
+	list, err := LoadBoostingList(writeTestFile(t, t.TempDir(), "catalogue.json", []byte(`["Verbio", {"term": "centralita", "weight": 2, "language": "es"}]`)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"centralita", "Verbio"}, list.Words("es-ES"))
+	assert.Equal(t, []string{"Verbio"}, list.Words("en-US"))
+
+	list, err = LoadBoostingList(writeTestFile(t, t.TempDir(), "catalogue.json", []byte(`{"*": ["Verbio"], "es-ES": ["centralita", {"term": "locución", "weight": 3}]}`)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"locución", "Verbio", "centralita"}, list.Words("es-ES"))
+	assert.Equal(t, []string{"Verbio"}, list.Words("es-MX"))
+}
+
+func TestLoadBoostingListErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		errMsg   string
+	}{
+		{"invalid weight", "list.txt", "verbio\nspeech | heavy\n", "line 2: invalid weight"},
+		{"negative weight", "list.txt", "speech | -1\n", "must be a positive number"},
+		{"empty term", "list.txt", "| 2\n", "empty term"},
+		{"no term column", "list.csv", "word,weight\nverbio,1\n", "no term column"},
+		{"csv weight", "list.csv", "term,weight\nverbio,1\nspeech,x\n", "line 3: invalid weight"},
+		{"json entry", "list.json", `["verbio", 3]`, "entry 2"},
+		{"json syntax", "list.json", `["verbio"`, "error parsing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBoostingList(writeTestFile(t, t.TempDir(), tt.file, []byte(tt.contents)))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	_, err := LoadBoostingList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestRecognitionOptionsBoostingList(t *testing.T) {
+	list, err := LoadBoostingList(writeTestFile(t, t.TempDir(), "catalogue.txt", []byte("verbio\n[es]\ncentralita | 2\n")))
+	assert.NoError(t, err)
+
+	options := RecognitionOptions{Topic: "generic", Language: "es-ES", WordBoosting: []string{"Verbio", "llamada"}, BoostingList: list}
+	assert.NoError(t, options.Validate())
+	request, err := options.request(GrammarAuto, 8000, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Verbio", "llamada", "centralita"}, request.GetConfig().GetParameters().GetWordBoosting())
+}
+
+func TestLargeBoostingListAccepted(t *testing.T) {
+	terms := make([]string, largeBoostingList+1)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("term %d", i)
+	}
+	terms = append(terms, strings.Repeat("a", longBoostedTermSize+1))
+	list, err := LoadBoostingList(writeTestFile(t, t.TempDir(), "large.txt", []byte(strings.Join(terms, "\n"))))
+	assert.NoError(t, err)
+	assert.Len(t, list.Terms, len(terms))
+
+	options := RecognitionOptions{Topic: "generic", BoostingList: list}
+	assert.NoError(t, options.Validate())
+	assert.Len(t, options.boostedWords(), len(terms))
+}